@@ -43,7 +43,7 @@ func main() {
 	cfg := consistent.WeightedConfig{
 		PartitionCount:    271,
 		ReplicationFactor: 50,
-		Load:              1,
+		Load:              1.25,
 		Hasher:            Hasher{},
 	}
 
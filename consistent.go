@@ -62,18 +62,34 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"sync"
 )
 
+// The defaults used for the zero fields of Config and WeightedConfig. Config() of a WeightedConsistent
+// returns its config with them filled in.
 const (
-	DefaultPartitionCount    int     = 271
-	DefaultReplicationFactor int     = 20
-	DefaultLoad              float64 = 1.25
+	// DefaultPartitionCount is the partition count of a Config without one. A WeightedConfig without one
+	// uses SuggestPartitionCount, which never suggests less than DefaultPartitionCount.
+	DefaultPartitionCount int = 271
+	// DefaultReplicationFactor is the number of replicas of a member, or of a unit of weight of a weighted
+	// member, if ReplicationFactor is 0.
+	DefaultReplicationFactor int = 20
+	// DefaultLoad is the load factor if Load is 0.
+	DefaultLoad float64 = 1.25
 )
 
 // ErrInsufficientMemberCount represents an error which means there are not enough members to complete the task.
 var ErrInsufficientMemberCount = errors.New("insufficient member count")
 
+// ErrInvalidCount represents an error which means the requested member count of GetClosestN and its
+// variants is not positive.
+var ErrInvalidCount = errors.New("count must be greater than 0")
+
+// ErrEmptyRing represents an error which means the ring has no members, so there is no meaningful answer. It
+// wraps ErrInsufficientMemberCount, which the empty rings returned before.
+var ErrEmptyRing = fmt.Errorf("ring is empty: %w", ErrInsufficientMemberCount)
+
 // Hasher is responsible for generating unsigned, 64-bit hash of provided byte slice.
 // Hasher should minimize collisions (generating same hash for different byte slice)
 // and while performance is also important fast functions are preferable (i.e.
@@ -103,6 +119,52 @@ type Config struct {
 
 	// Load is used to calculate average load. See the code, the paper and Google's blog post to learn about it.
 	Load float64
+
+	// ReplicaKeyFunc builds the key which is hashed to place the idx-th replica of a member on the ring.
+	// It's optional, the default length-prefixes the name so that different name and index pairs never
+	// produce the same key.
+	ReplicaKeyFunc func(name string, idx int) []byte
+
+	// Seed is mixed into the hashes of the replicas and the partition IDs. Rings with different
+	// seeds place members independently even if they share the same Hasher. Zero means no seed.
+	Seed uint64
+}
+
+// replicaKey is the default ReplicaKeyFunc. The name is prefixed with its length, so "server1" with
+// index 10 and "server11" with index 0 don't collide.
+func replicaKey(name string, idx int) []byte {
+	key := make([]byte, 0, len(name)+8)
+	key = strconv.AppendInt(key, int64(len(name)), 10)
+	key = append(key, ':')
+	key = append(key, name...)
+	return strconv.AppendInt(key, int64(idx), 10)
+}
+
+// seededKey prefixes the key with the seed if there is one.
+func seededKey(seed uint64, key []byte) []byte {
+	if seed == 0 {
+		return key
+	}
+	res := make([]byte, 8, 8+len(key))
+	binary.LittleEndian.PutUint64(res, seed)
+	return append(res, key...)
+}
+
+// partitionKey returns a buffer to hash partition IDs with hashPartition. The seed, if any, is
+// written in front of the partition ID.
+func partitionKey(seed uint64) []byte {
+	if seed == 0 {
+		return make([]byte, 8)
+	}
+	bs := make([]byte, 16)
+	binary.LittleEndian.PutUint64(bs, seed)
+	return bs
+}
+
+// hashPartition hashes the given partition ID using a buffer returned by partitionKey.
+func hashPartition(hasher Hasher, bs []byte, partID uint64) uint64 {
+	binary.LittleEndian.PutUint64(bs[len(bs)-8:], partID)
+	return hasher.Sum64(bs)
 }
 
 // Consistent holds the information about the members of the consistent hash circle.
@@ -117,6 +179,15 @@ type Consistent struct {
 	members        map[string]*Member
 	partitions     map[int]*Member
 	ring           map[uint64]*Member
+	// closest holds the members sorted by the hashes of their names, the order in which the replica owners
+	// are picked. It's updated by add and remove, so getClosestN doesn't hash and sort the names every time.
+	closest []closestEntry
+}
+
+// closestEntry is a member in Consistent.closest.
+type closestEntry struct {
+	hash   uint64
+	member *Member
 }
 
 // New creates and returns a new Consistent object.
@@ -133,6 +204,9 @@ func New(members []Member, config Config) *Consistent {
 	if config.Load == 0 {
 		config.Load = DefaultLoad
 	}
+	if config.ReplicaKeyFunc == nil {
+		config.ReplicaKeyFunc = replicaKey
+	}
 
 	c := &Consistent{
 		config:         config,
@@ -151,7 +225,8 @@ func New(members []Member, config Config) *Consistent {
 	return c
 }
 
-// GetMembers returns a thread-safe copy of members. If there are no members, it returns an empty slice of Member.
+// GetMembers returns a thread-safe copy of members sorted by name. If there are no members, it returns an empty
+// slice of Member.
 func (c *Consistent) GetMembers() []Member {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -161,6 +236,9 @@ func (c *Consistent) GetMembers() []Member {
 	for _, member := range c.members {
 		members = append(members, *member)
 	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].String() < members[j].String()
+	})
 	return members
 }
 
@@ -209,10 +287,9 @@ func (c *Consistent) distributePartitions() {
 	loads := make(map[string]float64)
 	partitions := make(map[int]*Member)
 
-	bs := make([]byte, 8)
+	bs := partitionKey(c.config.Seed)
 	for partID := uint64(0); partID < c.partitionCount; partID++ {
-		binary.LittleEndian.PutUint64(bs, partID)
-		key := c.hasher.Sum64(bs)
+		key := hashPartition(c.hasher, bs, partID)
 		idx := sort.Search(len(c.sortedSet), func(i int) bool {
 			return c.sortedSet[i] >= key
 		})
@@ -225,10 +302,14 @@ func (c *Consistent) distributePartitions() {
 	c.loads = loads
 }
 
+// replicaHash returns the position of the idx-th replica of the given member on the ring.
+func (c *Consistent) replicaHash(name string, idx int) uint64 {
+	return c.hasher.Sum64(seededKey(c.config.Seed, c.config.ReplicaKeyFunc(name, idx)))
+}
+
 func (c *Consistent) add(member Member) {
 	for i := 0; i < c.config.ReplicationFactor; i++ {
-		key := []byte(fmt.Sprintf("%s%d", member.String(), i))
-		h := c.hasher.Sum64(key)
+		h := c.replicaHash(member.String(), i)
 		c.ring[h] = &member
 		c.sortedSet = append(c.sortedSet, h)
 	}
@@ -236,10 +317,42 @@ func (c *Consistent) add(member Member) {
 	sort.Slice(c.sortedSet, func(i int, j int) bool {
 		return c.sortedSet[i] < c.sortedSet[j]
 	})
+	c.addClosest(&member)
 	// Storing member at this map is useful to find backup members of a partition.
 	c.members[member.String()] = &member
 }
 
+// addClosest inserts the member into closest, or replaces it if a member with the same name is already there.
+func (c *Consistent) addClosest(member *Member) {
+	name := (*member).String()
+	if idx := c.closestIndex(name); idx >= 0 {
+		c.closest[idx].member = member
+		return
+	}
+	h := c.hasher.Sum64([]byte(name))
+	idx := sort.Search(len(c.closest), func(i int) bool {
+		return c.closest[i].hash > h
+	})
+	c.closest = append(c.closest, closestEntry{})
+	copy(c.closest[idx+1:], c.closest[idx:])
+	c.closest[idx] = closestEntry{hash: h, member: member}
+}
+
+// closestIndex returns the index of the named member in closest, or -1 if it's not there.
+func (c *Consistent) closestIndex(name string) int {
+	h := c.hasher.Sum64([]byte(name))
+	idx := sort.Search(len(c.closest), func(i int) bool {
+		return c.closest[i].hash >= h
+	})
+	// The names may collide, check all the members with the same hash.
+	for ; idx < len(c.closest) && c.closest[idx].hash == h; idx++ {
+		if (*c.closest[idx].member).String() == name {
+			return idx
+		}
+	}
+	return -1
+}
+
 // Add adds a new member to the consistent hash circle.
 func (c *Consistent) Add(member Member) {
 	c.mu.Lock()
@@ -271,17 +384,32 @@ func (c *Consistent) Remove(name string) {
 		// There is no member with that name. Quit immediately.
 		return
 	}
+	c.remove(name)
+	c.redistribute()
+}
 
+// remove removes the replicas of a member from the ring without redistributing the partitions.
+func (c *Consistent) remove(name string) {
+	if _, ok := c.members[name]; !ok {
+		return
+	}
 	for i := 0; i < c.config.ReplicationFactor; i++ {
-		key := []byte(fmt.Sprintf("%s%d", name, i))
-		h := c.hasher.Sum64(key)
+		h := c.replicaHash(name, i)
 		delete(c.ring, h)
 		c.delSlice(h)
 	}
+	if idx := c.closestIndex(name); idx >= 0 {
+		c.closest = append(c.closest[:idx], c.closest[idx+1:]...)
+	}
 	delete(c.members, name)
+}
+
+// redistribute distributes the partitions among the members, or resets the partition table if the ring is empty.
+func (c *Consistent) redistribute() {
 	if len(c.members) == 0 {
 		// consistent hash ring is empty now. Reset the partition table.
 		c.partitions = make(map[int]*Member)
+		c.loads = make(map[string]float64)
 		return
 	}
 	c.distributePartitions()
@@ -330,57 +458,56 @@ func (c *Consistent) LocateKey(key []byte) Member {
 	return c.GetPartitionOwner(partID)
 }
 
-func (c *Consistent) getClosestN(partID, count int) ([]Member, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	var res []Member
-	if count > len(c.members) {
-		return res, ErrInsufficientMemberCount
-	}
-
-	var ownerKey uint64
+// walkClosest calls fn for every member in the order used to pick replica owners, starting with the owner of
+// the given partition, until fn returns false or all members are visited. It's not thread-safe.
+func (c *Consistent) walkClosest(partID int, fn func(Member) bool) {
 	owner := c.getPartitionOwner(partID)
-	// Hash and sort all the names.
-	var keys []uint64
-	kmems := make(map[uint64]*Member)
-	for name, member := range c.members {
-		key := c.hasher.Sum64([]byte(name))
-		if name == owner.String() {
-			ownerKey = key
-		}
-		keys = append(keys, key)
-		kmems[key] = member
+	if owner == nil {
+		return
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i] < keys[j]
-	})
 
 	// Find the key owner
-	idx := 0
-	for idx < len(keys) {
-		if keys[idx] == ownerKey {
-			key := keys[idx]
-			res = append(res, *kmems[key])
-			break
-		}
-		idx++
+	idx := c.closestIndex(owner.String())
+	if idx < 0 {
+		return
 	}
 
-	// Find the closest(replica owners) members.
-	for len(res) < count {
+	// Visit the owner and then the closest(replica owners) members.
+	for i := 0; i < len(c.closest); i++ {
+		if !fn(*c.closest[idx].member) {
+			return
+		}
 		idx++
-		if idx >= len(keys) {
+		if idx >= len(c.closest) {
 			idx = 0
 		}
-		key := keys[idx]
-		res = append(res, *kmems[key])
 	}
+}
+
+func (c *Consistent) getClosestN(partID, count int) ([]Member, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var res []Member
+	if count <= 0 {
+		return res, ErrInvalidCount
+	}
+	if len(c.members) == 0 {
+		return res, ErrEmptyRing
+	}
+	if count > len(c.members) {
+		return res, ErrInsufficientMemberCount
+	}
+
+	c.walkClosest(partID, func(member Member) bool {
+		res = append(res, member)
+		return len(res) < count
+	})
 	return res, nil
 }
 
 // GetClosestN returns the closest N member to a key in the hash ring.
-// This may be useful to find members for replication.
+// This may be useful to find members for replication. It returns ErrInvalidCount if count is not positive.
 func (c *Consistent) GetClosestN(key []byte, count int) ([]Member, error) {
 	partID := c.FindPartitionID(key)
 	return c.getClosestN(partID, count)
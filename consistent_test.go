@@ -143,6 +143,19 @@ func TestConsistentLocateKey(t *testing.T) {
 	}
 }
 
+func TestConsistentEmptyRing(t *testing.T) {
+	c := New(nil, newConfig())
+	if c.LocateKey([]byte("Olric")) != nil {
+		t.Fatal("Expected no owner on an empty ring")
+	}
+	if _, err := c.GetClosestN([]byte("Olric"), 1); err != ErrEmptyRing {
+		t.Fatalf("Expected ErrEmptyRing, got %v", err)
+	}
+	if c.AverageLoad() != 0 {
+		t.Fatalf("Expected zero average load, got %v", c.AverageLoad())
+	}
+}
+
 func TestConsistentInsufficientMemberCount(t *testing.T) {
 	var members []Member
 	for i := 0; i < 8; i++ {
@@ -158,6 +171,23 @@ func TestConsistentInsufficientMemberCount(t *testing.T) {
 	}
 }
 
+func TestConsistentInvalidCount(t *testing.T) {
+	var members []Member
+	for i := 0; i < 8; i++ {
+		member := testMember(fmt.Sprintf("node%d.olric", i))
+		members = append(members, member)
+	}
+	cfg := newConfig()
+	c := New(members, cfg)
+	key := []byte("Olric")
+	for _, count := range []int{0, -1} {
+		_, err := c.GetClosestN(key, count)
+		if err != ErrInvalidCount {
+			t.Fatalf("Expected ErrInvalidCount(%v), Got: %v", ErrInvalidCount, err)
+		}
+	}
+}
+
 func TestConsistentClosestMembers(t *testing.T) {
 	var members []Member
 	for i := 0; i < 8; i++ {
@@ -183,6 +213,29 @@ func TestConsistentClosestMembers(t *testing.T) {
 	}
 }
 
+func TestConsistentSeed(t *testing.T) {
+	var members []Member
+	for i := 0; i < 4; i++ {
+		members = append(members, testMember(fmt.Sprintf("node%d.olric", i)))
+	}
+	cfg := newConfig()
+	cfg.PartitionCount = 271
+	c1 := New(members, cfg)
+	cfg.Seed = 42
+	c2 := New(members, cfg)
+
+	var differ int
+	for i := 0; i < 10000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if c1.LocateKey(key).String() != c2.LocateKey(key).String() {
+			differ++
+		}
+	}
+	if ratio := float64(differ) / 10000; ratio < 0.5 {
+		t.Fatalf("Expected rings with different seeds to disagree on most keys, got %.2f", ratio)
+	}
+}
+
 func BenchmarkAddRemove(b *testing.B) {
 	cfg := newConfig()
 	c := New(nil, cfg)
@@ -218,3 +271,51 @@ func BenchmarkGetClosestN(b *testing.B) {
 		_, _ = c.GetClosestN(key, 3)
 	}
 }
+
+func BenchmarkGetClosestN_ManyMembers(b *testing.B) {
+	cfg := newConfig()
+	cfg.PartitionCount = 7919
+	members := make([]Member, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		members = append(members, testMember(fmt.Sprintf("node%d", i)))
+	}
+	c := New(members, cfg)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := []byte("key" + strconv.Itoa(i))
+		_, _ = c.GetClosestN(key, 3)
+	}
+}
+
+func TestConsistentReplicaKeyCollision(t *testing.T) {
+	cfg := newConfig()
+	c := New([]Member{testMember("server1"), testMember("server11")}, cfg)
+	if len(c.sortedSet) != 2*cfg.ReplicationFactor {
+		t.Fatalf("Expected %d positions on the ring, got %d", 2*cfg.ReplicationFactor, len(c.sortedSet))
+	}
+	if len(c.ring) != len(c.sortedSet) {
+		t.Fatalf("Replicas collided: %d distinct positions for %d replicas", len(c.ring), len(c.sortedSet))
+	}
+}
+
+func TestConsistentReplicaKeyFunc(t *testing.T) {
+	cfg := newConfig()
+	var calls int
+	cfg.ReplicaKeyFunc = func(name string, idx int) []byte {
+		calls++
+		return []byte(fmt.Sprintf("%s-%d", name, idx))
+	}
+	c := New([]Member{testMember("node1.olric")}, cfg)
+	if calls != cfg.ReplicationFactor {
+		t.Fatalf("Expected %d calls to ReplicaKeyFunc, got %d", cfg.ReplicationFactor, calls)
+	}
+	if _, ok := c.ring[cfg.Hasher.Sum64([]byte("node1.olric-0"))]; !ok {
+		t.Fatal("Expected a replica placed by ReplicaKeyFunc")
+	}
+
+	c.Remove("node1.olric")
+	if len(c.sortedSet) != 0 || len(c.ring) != 0 {
+		t.Fatalf("Expected an empty ring, got %d positions", len(c.sortedSet))
+	}
+}
@@ -1,5 +1,5 @@
 module github.com/buraksezer/consistent
 
-go 1.9
+go 1.18
 
-require github.com/cespare/xxhash v1.1.0 // indirect
+require github.com/cespare/xxhash v1.1.0
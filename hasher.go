@@ -0,0 +1,56 @@
+package consistent
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/cespare/xxhash"
+)
+
+// DefaultHasher implements Hasher with the 64-bit xxHash algorithm (XXH64, seed 0) of
+// github.com/cespare/xxhash. It's fast and has a good avalanche behavior for similar keys, unlike FNV.
+type DefaultHasher struct{}
+
+// Sum64 returns the XXH64 hash of data.
+func (DefaultHasher) Sum64(data []byte) uint64 {
+	return xxhash.Sum64(data)
+}
+
+// uniformityBuckets is the number of buckets of CheckHasherUniformity. The keys are put into the buckets
+// with the same modulo operation which maps them to partitions, DefaultPartitionCount is used as the
+// bucket count.
+const uniformityBuckets = DefaultPartitionCount
+
+// CheckHasherUniformity hashes sampleKeys synthetic keys ("key-0", "key-1", ...) into buckets and returns
+// the chi-square statistic of the bucket counts against the uniform distribution. ok is false if the
+// statistic exceeds the critical value at the 0.001 significance level, about 347.6 for the 270 degrees
+// of freedom: a good hasher fails the check once in a thousand samples, so a hasher which fails it
+// skews the rings. sampleKeys is raised to 100 keys per bucket if it's smaller.
+func CheckHasherUniformity(h Hasher, sampleKeys int) (chiSquare float64, ok bool) {
+	if sampleKeys < 100*uniformityBuckets {
+		sampleKeys = 100 * uniformityBuckets
+	}
+
+	counts := make([]int, uniformityBuckets)
+	key := make([]byte, 0, 32)
+	for i := 0; i < sampleKeys; i++ {
+		key = strconv.AppendInt(append(key[:0], "key-"...), int64(i), 10)
+		counts[h.Sum64(key)%uint64(uniformityBuckets)]++
+	}
+
+	expected := float64(sampleKeys) / float64(uniformityBuckets)
+	for _, count := range counts {
+		d := float64(count) - expected
+		chiSquare += d * d / expected
+	}
+	return chiSquare, chiSquare <= chiSquareCritical(uniformityBuckets-1)
+}
+
+// chiSquareCritical approximates the critical value of the chi-square distribution with the given
+// degrees of freedom at the 0.001 significance level with the Wilson-Hilferty transformation.
+func chiSquareCritical(df int) float64 {
+	const z = 3.090232 // The 0.999 quantile of the standard normal distribution.
+	k := float64(df)
+	a := 2 / (9 * k)
+	return k * math.Pow(1-a+z*math.Sqrt(a), 3)
+}
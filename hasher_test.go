@@ -0,0 +1,72 @@
+package consistent
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDefaultHasher_Sum64(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"as", 0x1c330fb2d66be179},
+		{"asd", 0x631c37ce72a97393},
+		{"asdf", 0x415872f599cea71e},
+		{"abc", 0x44bc2cf5ad770999},
+		{"The quick brown fox jumps over the lazy dog", 0x0b242d361fda71bc},
+		{"Call me Ishmael. Some years ago--never mind how long precisely-", 0x02a2e85470d6fd96},
+	}
+
+	var h DefaultHasher
+	for _, tt := range tests {
+		if got := h.Sum64([]byte(tt.input)); got != tt.want {
+			t.Fatalf("Expected %#x for %q, got %#x", tt.want, tt.input, got)
+		}
+	}
+}
+
+func TestNewWeighted_DefaultHasher(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 2},
+	}
+
+	c := NewWeighted(members, WeightedConfig{})
+	if _, ok := c.hasher.(DefaultHasher); !ok {
+		t.Fatalf("Expected DefaultHasher, got %T", c.hasher)
+	}
+
+	explicit := NewWeighted(members, WeightedConfig{Hasher: DefaultHasher{}})
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if c.LocateKey(key).String() != explicit.LocateKey(key).String() {
+			t.Fatalf("Expected the same owner for %s", key)
+		}
+	}
+}
+
+type lengthHasher struct{}
+
+func (lengthHasher) Sum64(data []byte) uint64 {
+	return uint64(len(data))
+}
+
+func TestCheckHasherUniformity(t *testing.T) {
+	if critical := chiSquareCritical(270); math.Abs(critical-347.6) > 0.1 {
+		t.Fatalf("Expected the critical value to be about 347.6, got %v", critical)
+	}
+
+	chiSquare, ok := CheckHasherUniformity(DefaultHasher{}, 100000)
+	if !ok {
+		t.Fatalf("Expected DefaultHasher to pass, chi-square: %v", chiSquare)
+	}
+
+	chiSquare, ok = CheckHasherUniformity(lengthHasher{}, 100000)
+	if ok {
+		t.Fatalf("Expected the key length hasher to fail, chi-square: %v", chiSquare)
+	}
+}
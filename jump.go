@@ -0,0 +1,126 @@
+package consistent
+
+import (
+	"sync"
+)
+
+// Jump implements weighted jump consistent hashing (Lamping & Veach, https://arxiv.org/abs/1406.2294).
+// Keys are mapped to a bucket index with jump hash and every member owns a number of buckets
+// proportional to its weight. There is no ring to keep in memory and the balance is near perfect.
+//
+// Jump hash only supports adding and removing buckets at the end of the bucket list. Members own
+// buckets in insertion order, so adding a member or removing the most recently added one moves
+// only the keys that have to move. Removing any other member shifts the buckets of every member
+// added after it and reshuffles considerably more keys. Use WeightedConsistent or Rendezvous if
+// arbitrary members leave the cluster frequently.
+type Jump struct {
+	mu sync.RWMutex
+
+	hasher  Hasher
+	members map[string]WeightedMember
+	order   []string
+	buckets []string
+}
+
+// NewJump creates and returns a new Jump object. DefaultHasher is used if hasher is nil.
+func NewJump(members []WeightedMember, hasher Hasher) *Jump {
+	if hasher == nil {
+		hasher = DefaultHasher{}
+	}
+	j := &Jump{
+		hasher:  hasher,
+		members: make(map[string]WeightedMember),
+	}
+	for _, member := range members {
+		if _, ok := j.members[member.String()]; ok {
+			continue
+		}
+		j.members[member.String()] = member
+		j.order = append(j.order, member.String())
+	}
+	j.buildBuckets()
+	return j
+}
+
+// buildBuckets rebuilds the bucket-to-member table. Every member owns weight consecutive buckets,
+// in insertion order.
+func (j *Jump) buildBuckets() {
+	buckets := make([]string, 0, len(j.buckets))
+	for _, name := range j.order {
+		weight := j.members[name].Weight()
+		if weight <= 0 {
+			weight = 1 // Ensure minimum weight of 1
+		}
+		for i := 0; i < weight; i++ {
+			buckets = append(buckets, name)
+		}
+	}
+	j.buckets = buckets
+}
+
+// Add adds a new weighted member. The member owns the buckets appended at the end of the table.
+func (j *Jump) Add(member WeightedMember) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.members[member.String()]; ok {
+		return
+	}
+	j.members[member.String()] = member
+	j.order = append(j.order, member.String())
+	j.buildBuckets()
+}
+
+// Remove removes a weighted member. See the Jump documentation for the cost of removing
+// a member other than the last one added.
+func (j *Jump) Remove(name string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.members[name]; !ok {
+		return
+	}
+	delete(j.members, name)
+	for i, n := range j.order {
+		if n == name {
+			j.order = append(j.order[:i], j.order[i+1:]...)
+			break
+		}
+	}
+	j.buildBuckets()
+}
+
+// GetMembers returns a thread-safe copy of members in insertion order.
+func (j *Jump) GetMembers() []WeightedMember {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	members := make([]WeightedMember, 0, len(j.order))
+	for _, name := range j.order {
+		members = append(members, j.members[name])
+	}
+	return members
+}
+
+// jumpHash maps key to a bucket in [0, numBuckets).
+func jumpHash(key uint64, numBuckets int) int {
+	var b, k int64 = -1, 0
+	for k < int64(numBuckets) {
+		b = k
+		key = key*2862933555777941757 + 1
+		k = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// LocateKey finds a home for given key. It returns nil if there are no members.
+func (j *Jump) LocateKey(key []byte) WeightedMember {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if len(j.buckets) == 0 {
+		return nil
+	}
+	bucket := jumpHash(j.hasher.Sum64(key), len(j.buckets))
+	return j.members[j.buckets[bucket]]
+}
@@ -0,0 +1,102 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestJump_LocateKey(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+	}
+
+	j := NewJump(members, testHasher{})
+
+	key := []byte("test-key")
+	member := j.LocateKey(key)
+	if member == nil {
+		t.Fatal("LocateKey returned nil")
+	}
+	for i := 0; i < 10; i++ {
+		if j.LocateKey(key).String() != member.String() {
+			t.Fatal("LocateKey returned different members for the same key")
+		}
+	}
+
+	if NewJump(nil, testHasher{}).LocateKey(key) != nil {
+		t.Fatal("Expected nil on an empty ring")
+	}
+
+	// DefaultHasher is used if the hasher is nil.
+	d := NewJump(members, nil)
+	if d.LocateKey(key).String() != NewJump(members, DefaultHasher{}).LocateKey(key).String() {
+		t.Fatal("Expected the owners of DefaultHasher")
+	}
+}
+
+func TestJump_WeightedDistribution(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 2},
+		testWeightedMember{name: "node3.olric", weight: 5},
+	}
+
+	j := NewJump(members, testHasher{})
+
+	counts := make(map[string]int)
+	for i := 0; i < 80000; i++ {
+		counts[j.LocateKey([]byte(fmt.Sprintf("key-%d", i))).String()]++
+	}
+	for _, member := range members {
+		expected := 10000 * member.Weight()
+		got := counts[member.String()]
+		if got < expected*9/10 || got > expected*11/10 {
+			t.Fatalf("Expected about %d keys on %s, got %d", expected, member, got)
+		}
+	}
+}
+
+func TestJump_AddMovesKeysOnlyToNewMember(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 3) + 1,
+		})
+	}
+
+	j := NewJump(members, testHasher{})
+
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = j.LocateKey([]byte(key)).String()
+	}
+
+	j.Add(testWeightedMember{name: "node8.olric", weight: 2})
+	for key, owner := range before {
+		current := j.LocateKey([]byte(key)).String()
+		if current != owner && current != "node8.olric" {
+			t.Fatalf("%s moved from %s to %s", key, owner, current)
+		}
+	}
+
+	// Removing the last member restores the previous assignment.
+	j.Remove("node8.olric")
+	if len(j.GetMembers()) != 8 {
+		t.Fatalf("Expected 8 members, got %d", len(j.GetMembers()))
+	}
+	for key, owner := range before {
+		if current := j.LocateKey([]byte(key)).String(); current != owner {
+			t.Fatalf("%s moved from %s to %s", key, owner, current)
+		}
+	}
+
+	j.Remove("node3.olric")
+	for key := range before {
+		if j.LocateKey([]byte(key)).String() == "node3.olric" {
+			t.Fatalf("%s is still owned by the removed member", key)
+		}
+	}
+}
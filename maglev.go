@@ -0,0 +1,185 @@
+package consistent
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultMaglevTableSize is the lookup table size used by NewMaglev when tableSize is not positive.
+const DefaultMaglevTableSize = 65537
+
+// Maglev implements weighted Maglev hashing (Eisenbud et al., https://research.google/pubs/pub44824/).
+// Every member fills the slots of a lookup table in the order of its own permutation of the table and
+// takes turns in proportion to its weight, so LocateKey is a single hash and an array index.
+//
+// The table is rebuilt from scratch on every Add and Remove. Unlike the ring, the disruption is not
+// minimal: besides the keys which have to move to or from the changed member, a small fraction of
+// the other keys moves between the remaining members. There are no load bounds either, the balance
+// depends on the table size, which should be much larger than the total weight of the members.
+// Use WeightedConsistent if the keys have to stay put, or Rendezvous for small clusters.
+type Maglev struct {
+	mu sync.RWMutex
+
+	hasher  Hasher
+	size    uint64
+	members map[string]WeightedMember
+	names   []string
+	table   []WeightedMember
+}
+
+// NewMaglev creates and returns a new Maglev object. The permutations require a prime table size,
+// so tableSize is rounded up to the next prime. DefaultMaglevTableSize is used if it's not positive and
+// DefaultHasher if hasher is nil.
+func NewMaglev(members []WeightedMember, tableSize int, hasher Hasher) *Maglev {
+	if hasher == nil {
+		hasher = DefaultHasher{}
+	}
+	if tableSize <= 0 {
+		tableSize = DefaultMaglevTableSize
+	}
+	m := &Maglev{
+		hasher:  hasher,
+		size:    nextPrime(uint64(tableSize)),
+		members: make(map[string]WeightedMember),
+	}
+	for _, member := range members {
+		m.add(member)
+	}
+	m.buildTable()
+	return m
+}
+
+// nextPrime returns the smallest prime which is not less than n.
+func nextPrime(n uint64) uint64 {
+	if n <= 2 {
+		return 2
+	}
+	if n%2 == 0 {
+		n++
+	}
+	for ; ; n += 2 {
+		prime := true
+		for d := uint64(3); d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+func (m *Maglev) add(member WeightedMember) bool {
+	if _, ok := m.members[member.String()]; ok {
+		return false
+	}
+	m.members[member.String()] = member
+
+	idx := sort.SearchStrings(m.names, member.String())
+	m.names = append(m.names, "")
+	copy(m.names[idx+1:], m.names[idx:])
+	m.names[idx] = member.String()
+	return true
+}
+
+// buildTable fills the lookup table. The members take turns in name order and every member claims
+// weight slots per turn, the next free slots of its permutation (offset + j*skip) mod size.
+func (m *Maglev) buildTable() {
+	if len(m.names) == 0 {
+		m.table = nil
+		return
+	}
+
+	offsets := make([]uint64, len(m.names))
+	skips := make([]uint64, len(m.names))
+	weights := make([]int, len(m.names))
+	for i, name := range m.names {
+		offsets[i] = m.hasher.Sum64([]byte(name)) % m.size
+		skips[i] = m.hasher.Sum64([]byte(name+"-skip"))%(m.size-1) + 1
+		weights[i] = m.members[name].Weight()
+		if weights[i] <= 0 {
+			weights[i] = 1 // Ensure minimum weight of 1
+		}
+	}
+
+	slots := make([]int, m.size)
+	for i := range slots {
+		slots[i] = -1
+	}
+	next := make([]uint64, len(m.names))
+	var filled uint64
+	for filled < m.size {
+		for i := range m.names {
+			for w := 0; w < weights[i] && filled < m.size; w++ {
+				slot := (offsets[i] + next[i]*skips[i]) % m.size
+				for slots[slot] >= 0 {
+					next[i]++
+					slot = (offsets[i] + next[i]*skips[i]) % m.size
+				}
+				slots[slot] = i
+				next[i]++
+				filled++
+			}
+		}
+	}
+
+	table := make([]WeightedMember, m.size)
+	for slot, i := range slots {
+		table[slot] = m.members[m.names[i]]
+	}
+	m.table = table
+}
+
+// Add adds a new weighted member and rebuilds the lookup table.
+func (m *Maglev) Add(member WeightedMember) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.add(member) {
+		m.buildTable()
+	}
+}
+
+// Remove removes a weighted member and rebuilds the lookup table.
+func (m *Maglev) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.members[name]; !ok {
+		return
+	}
+	delete(m.members, name)
+	idx := sort.SearchStrings(m.names, name)
+	m.names = append(m.names[:idx], m.names[idx+1:]...)
+	m.buildTable()
+}
+
+// GetMembers returns a thread-safe copy of members.
+func (m *Maglev) GetMembers() []WeightedMember {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	members := make([]WeightedMember, 0, len(m.names))
+	for _, name := range m.names {
+		members = append(members, m.members[name])
+	}
+	return members
+}
+
+// TableSize returns the size of the lookup table.
+func (m *Maglev) TableSize() int {
+	return int(m.size)
+}
+
+// LocateKey finds a home for given key. It returns nil if there are no members.
+func (m *Maglev) LocateKey(key []byte) WeightedMember {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.table == nil {
+		return nil
+	}
+	return m.table[m.hasher.Sum64(key)%m.size]
+}
@@ -0,0 +1,102 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMaglev_LocateKey(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+	}
+
+	m := NewMaglev(members, 0, testHasher{})
+	if m.TableSize() != DefaultMaglevTableSize {
+		t.Fatalf("Expected table size %d, got %d", DefaultMaglevTableSize, m.TableSize())
+	}
+
+	key := []byte("test-key")
+	member := m.LocateKey(key)
+	if member == nil {
+		t.Fatal("LocateKey returned nil")
+	}
+	for i := 0; i < 10; i++ {
+		if m.LocateKey(key).String() != member.String() {
+			t.Fatal("LocateKey returned different members for the same key")
+		}
+	}
+
+	if NewMaglev(nil, 0, testHasher{}).LocateKey(key) != nil {
+		t.Fatal("Expected nil on an empty table")
+	}
+	if size := NewMaglev(nil, 1000, testHasher{}).TableSize(); size != 1009 {
+		t.Fatalf("Expected table size 1009, got %d", size)
+	}
+
+	// DefaultHasher is used if the hasher is nil.
+	d := NewMaglev(members, 0, nil)
+	if d.LocateKey(key).String() != NewMaglev(members, 0, DefaultHasher{}).LocateKey(key).String() {
+		t.Fatal("Expected the table of DefaultHasher")
+	}
+}
+
+func TestMaglev_WeightedDistribution(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 2},
+		testWeightedMember{name: "node3.olric", weight: 5},
+	}
+
+	m := NewMaglev(members, 0, testHasher{})
+
+	counts := make(map[string]int)
+	for i := 0; i < 80000; i++ {
+		counts[m.LocateKey([]byte(fmt.Sprintf("key-%d", i))).String()]++
+	}
+	for _, member := range members {
+		expected := 10000 * member.Weight()
+		got := counts[member.String()]
+		if got < expected*9/10 || got > expected*11/10 {
+			t.Fatalf("Expected about %d keys on %s, got %d", expected, member, got)
+		}
+	}
+}
+
+func TestMaglev_Remove(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 3) + 1,
+		})
+	}
+
+	m := NewMaglev(members, 0, testHasher{})
+
+	before := make(map[string]string)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = m.LocateKey([]byte(key)).String()
+	}
+
+	m.Remove("node3.olric")
+	if len(m.GetMembers()) != 7 {
+		t.Fatalf("Expected 7 members, got %d", len(m.GetMembers()))
+	}
+
+	var moved int
+	for key, owner := range before {
+		current := m.LocateKey([]byte(key)).String()
+		if current == "node3.olric" {
+			t.Fatalf("%s is still owned by the removed member", key)
+		}
+		if owner != "node3.olric" && current != owner {
+			moved++
+		}
+	}
+	// Maglev's disruption is not minimal, but most of the other keys stay put.
+	if moved > len(before)/10 {
+		t.Fatalf("Expected at most %d other keys to move, got %d", len(before)/10, moved)
+	}
+}
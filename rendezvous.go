@@ -0,0 +1,154 @@
+package consistent
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Rendezvous implements weighted rendezvous (highest random weight) hashing. It's a drop-in alternative
+// to WeightedConsistent for small clusters: there are no partitions and no load bounds, every key is
+// owned by the member with the highest score. Removing a member only moves the keys it owned.
+type Rendezvous struct {
+	mu sync.RWMutex
+
+	hasher  Hasher
+	members map[string]WeightedMember
+	weights map[string]int
+	names   []string
+}
+
+// NewRendezvous creates and returns a new Rendezvous object. DefaultHasher is used if hasher is nil.
+func NewRendezvous(members []WeightedMember, hasher Hasher) *Rendezvous {
+	if hasher == nil {
+		hasher = DefaultHasher{}
+	}
+	r := &Rendezvous{
+		hasher:  hasher,
+		members: make(map[string]WeightedMember),
+		weights: make(map[string]int),
+	}
+	for _, member := range members {
+		r.add(member)
+	}
+	return r
+}
+
+func (r *Rendezvous) add(member WeightedMember) {
+	if _, ok := r.members[member.String()]; ok {
+		return
+	}
+	weight := member.Weight()
+	if weight <= 0 {
+		weight = 1 // Ensure minimum weight of 1
+	}
+	r.members[member.String()] = member
+	r.weights[member.String()] = weight
+
+	idx := sort.SearchStrings(r.names, member.String())
+	r.names = append(r.names, "")
+	copy(r.names[idx+1:], r.names[idx:])
+	r.names[idx] = member.String()
+}
+
+// Add adds a new weighted member.
+func (r *Rendezvous) Add(member WeightedMember) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.add(member)
+}
+
+// Remove removes a weighted member.
+func (r *Rendezvous) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.members[name]; !ok {
+		return
+	}
+	delete(r.members, name)
+	delete(r.weights, name)
+	idx := sort.SearchStrings(r.names, name)
+	r.names = append(r.names[:idx], r.names[idx+1:]...)
+}
+
+// GetMembers returns a thread-safe copy of members.
+func (r *Rendezvous) GetMembers() []WeightedMember {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	members := make([]WeightedMember, 0, len(r.names))
+	for _, name := range r.names {
+		members = append(members, r.members[name])
+	}
+	return members
+}
+
+// score calculates the weighted score of a member for the given key: -weight / ln(h), where h is
+// hash(key+member) mapped to (0, 1). The member with the highest score owns the key.
+func (r *Rendezvous) score(buf []byte, name string) float64 {
+	buf = append(buf, name...)
+	h := (float64(r.hasher.Sum64(buf)) + 0.5) / (1 << 64)
+	if h >= 1 {
+		h = math.Nextafter(1, 0)
+	}
+	return -float64(r.weights[name]) / math.Log(h)
+}
+
+// LocateKey finds a home for given key. It returns nil if there are no members.
+func (r *Rendezvous) LocateKey(key []byte) WeightedMember {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	buf := make([]byte, len(key), len(key)+32)
+	copy(buf, key)
+
+	var owner string
+	best := -1.0
+	for _, name := range r.names {
+		if s := r.score(buf, name); s > best {
+			best, owner = s, name
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	return r.members[owner]
+}
+
+// GetClosestN returns the N members with the highest scores for the given key, the owner first. It returns
+// ErrInvalidCount if count is not positive.
+func (r *Rendezvous) GetClosestN(key []byte, count int) ([]WeightedMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if count <= 0 {
+		return nil, ErrInvalidCount
+	}
+	if len(r.names) == 0 {
+		return nil, ErrEmptyRing
+	}
+	if count > len(r.names) {
+		return nil, ErrInsufficientMemberCount
+	}
+
+	buf := make([]byte, len(key), len(key)+32)
+	copy(buf, key)
+
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+	scores := make(map[string]float64, len(names))
+	for _, name := range names {
+		scores[name] = r.score(buf, name)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return scores[names[i]] > scores[names[j]]
+	})
+
+	res := make([]WeightedMember, 0, count)
+	for _, name := range names[:count] {
+		res = append(res, r.members[name])
+	}
+	return res, nil
+}
@@ -0,0 +1,123 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRendezvous_LocateKey(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+	}
+
+	r := NewRendezvous(members, testHasher{})
+
+	key := []byte("test-key")
+	member := r.LocateKey(key)
+	if member == nil {
+		t.Fatal("LocateKey returned nil")
+	}
+	for i := 0; i < 10; i++ {
+		if r.LocateKey(key).String() != member.String() {
+			t.Fatal("LocateKey returned different members for the same key")
+		}
+	}
+
+	if NewRendezvous(nil, testHasher{}).LocateKey(key) != nil {
+		t.Fatal("Expected nil on an empty ring")
+	}
+
+	// DefaultHasher is used if the hasher is nil.
+	d := NewRendezvous(members, nil)
+	if d.LocateKey(key).String() != NewRendezvous(members, DefaultHasher{}).LocateKey(key).String() {
+		t.Fatal("Expected the owners of DefaultHasher")
+	}
+}
+
+func TestRendezvous_RemoveMovesOnlyOwnedKeys(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 3) + 1,
+		})
+	}
+
+	r := NewRendezvous(members, testHasher{})
+
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = r.LocateKey([]byte(key)).String()
+	}
+
+	r.Remove("node3.olric")
+	if len(r.GetMembers()) != 7 {
+		t.Fatalf("Expected 7 members, got %d", len(r.GetMembers()))
+	}
+
+	for key, owner := range before {
+		current := r.LocateKey([]byte(key)).String()
+		if owner != "node3.olric" && current != owner {
+			t.Fatalf("%s moved from %s to %s", key, owner, current)
+		}
+		if current == "node3.olric" {
+			t.Fatalf("%s is still owned by the removed member", key)
+		}
+	}
+}
+
+func TestRendezvous_Weights(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "heavy", weight: 4},
+		testWeightedMember{name: "light", weight: 1},
+	}
+
+	r := NewRendezvous(members, testHasher{})
+
+	distribution := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		distribution[r.LocateKey([]byte(fmt.Sprintf("key-%d", i))).String()]++
+	}
+
+	ratio := float64(distribution["heavy"]) / float64(distribution["light"])
+	if ratio < 3 || ratio > 5 {
+		t.Fatalf("Expected a ratio of about 4:1, got %.2f:1", ratio)
+	}
+}
+
+func TestRendezvous_GetClosestN(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+		testWeightedMember{name: "server3", weight: 3},
+	}
+
+	r := NewRendezvous(members, testHasher{})
+
+	key := []byte("test-key")
+	closest, err := r.GetClosestN(key, 3)
+	if err != nil {
+		t.Fatalf("GetClosestN returned error: %v", err)
+	}
+	if len(closest) != 3 {
+		t.Fatalf("Expected 3 closest members, got %d", len(closest))
+	}
+	if closest[0].String() != r.LocateKey(key).String() {
+		t.Fatalf("Expected the owner as the first member, got %s", closest[0])
+	}
+
+	// The second choice becomes the owner when the first one is removed.
+	r.Remove(closest[0].String())
+	if r.LocateKey(key).String() != closest[1].String() {
+		t.Fatalf("Expected %s to own the key, got %s", closest[1], r.LocateKey(key))
+	}
+
+	if _, err := r.GetClosestN(key, 3); err != ErrInsufficientMemberCount {
+		t.Fatalf("Expected ErrInsufficientMemberCount, got %v", err)
+	}
+	if _, err := r.GetClosestN(key, -1); err != ErrInvalidCount {
+		t.Fatalf("Expected ErrInvalidCount, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package consistent
+
+// TypedRing is a type-safe wrapper around WeightedConsistent. It stores members of the concrete
+// type M and returns them without interface assertions on the caller side.
+type TypedRing[M WeightedMember] struct {
+	ring *WeightedConsistent
+}
+
+// NewTypedRing creates and returns a new TypedRing object.
+func NewTypedRing[M WeightedMember](members []M, config WeightedConfig) *TypedRing[M] {
+	wmembers := make([]WeightedMember, 0, len(members))
+	for _, member := range members {
+		wmembers = append(wmembers, member)
+	}
+	if members == nil {
+		wmembers = nil
+	}
+	return &TypedRing[M]{ring: NewWeighted(wmembers, config)}
+}
+
+// Ring returns the underlying WeightedConsistent object.
+func (r *TypedRing[M]) Ring() *WeightedConsistent {
+	return r.ring
+}
+
+// Add adds a new member to the ring.
+func (r *TypedRing[M]) Add(member M) {
+	r.ring.Add(member)
+}
+
+// Remove removes a member from the ring.
+func (r *TypedRing[M]) Remove(name string) {
+	r.ring.Remove(name)
+}
+
+// LocateKey finds a home for given key. It returns the zero value of M if the ring is empty.
+func (r *TypedRing[M]) LocateKey(key []byte) M {
+	member, _ := r.ring.LocateKey(key).(M)
+	return member
+}
+
+// GetClosestN returns the closest N members to a key in the hash ring.
+func (r *TypedRing[M]) GetClosestN(key []byte, count int) ([]M, error) {
+	members, err := r.ring.GetClosestN(key, count)
+	if err != nil {
+		return nil, err
+	}
+	return typedMembers[M](members), nil
+}
+
+// GetMembers returns a thread-safe copy of members.
+func (r *TypedRing[M]) GetMembers() []M {
+	return typedMembers[M](r.ring.GetMembers())
+}
+
+// typedMembers converts the members to M. Only members of type M are ever added to a TypedRing.
+func typedMembers[M WeightedMember](members []WeightedMember) []M {
+	res := make([]M, 0, len(members))
+	for _, member := range members {
+		res = append(res, member.(M))
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+package consistent
+
+import (
+	"fmt"
+	"testing"
+)
+
+type typedTestMember struct {
+	name   string
+	weight int
+	region string
+}
+
+func (m *typedTestMember) String() string {
+	return m.name
+}
+
+func (m *typedTestMember) Weight() int {
+	return m.weight
+}
+
+func TestTypedRing(t *testing.T) {
+	members := []*typedTestMember{
+		{name: "server1", weight: 2, region: "eu"},
+		{name: "server2", weight: 1, region: "us"},
+		{name: "server3", weight: 3, region: "ap"},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	r := NewTypedRing(members, cfg)
+
+	regions := make(map[string]string)
+	for _, member := range members {
+		regions[member.name] = member.region
+	}
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		member := r.LocateKey(key)
+		if member == nil {
+			t.Fatalf("LocateKey returned nil for %s", key)
+		}
+		if member.region != regions[member.name] {
+			t.Fatalf("Expected region %s for %s, got %s", regions[member.name], member.name, member.region)
+		}
+		if member.name != r.Ring().LocateKey(key).String() {
+			t.Fatalf("TypedRing and the underlying ring disagree on %s", key)
+		}
+	}
+
+	closest, err := r.GetClosestN([]byte("test-key"), 2)
+	if err != nil {
+		t.Fatalf("GetClosestN returned error: %v", err)
+	}
+	if len(closest) != 2 {
+		t.Fatalf("Expected 2 closest members, got %d", len(closest))
+	}
+
+	r.Add(&typedTestMember{name: "server4", weight: 1, region: "eu"})
+	r.Remove("server1")
+	if len(r.GetMembers()) != 3 {
+		t.Fatalf("Expected 3 members, got %d", len(r.GetMembers()))
+	}
+
+	if _, err := r.GetClosestN([]byte("test-key"), 5); err != ErrInsufficientMemberCount {
+		t.Fatalf("Expected ErrInsufficientMemberCount, got %v", err)
+	}
+}
+
+func TestTypedRingEmpty(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	r := NewTypedRing[*typedTestMember](nil, cfg)
+	if member := r.LocateKey([]byte("test-key")); member != nil {
+		t.Fatalf("Expected nil on an empty ring, got %v", member)
+	}
+	if len(r.GetMembers()) != 0 {
+		t.Fatalf("Expected 0 members, got %d", len(r.GetMembers()))
+	}
+}
@@ -27,59 +27,393 @@
 package consistent
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var (
+	// ErrMemberNotFound represents an error which means the requested member is not in the ring.
+	ErrMemberNotFound = errors.New("member not found")
+
+	// ErrNotEnoughRoom represents an error which means the partitions cannot be distributed among the members
+	// without exceeding their expected loads. Decrease the partition count, add more members or increase the load factor.
+	// It's wrapped by a DistributionError, use errors.Is to check for it.
+	ErrNotEnoughRoom = errors.New("not enough room to distribute partitions")
+
+	// ErrTooManyReplicas represents an error which means ReplicationFactor multiplied by the weight of a member
+	// exceeds WeightedConfig.MaxReplicasPerMember.
+	ErrTooManyReplicas = errors.New("too many replicas for a member")
+
+	// ErrInvalidLoad represents an error which means WeightedConfig.Load is not greater than 1. Bounded loads
+	// need some slack above the average load, otherwise the partitions often don't fit the members.
+	ErrInvalidLoad = errors.New("load must be greater than 1")
+
+	// ErrInvalidPartitionCount represents an error which means the requested partition count is not positive.
+	ErrInvalidPartitionCount = errors.New("partition count must be greater than 0")
+
+	// ErrHashKeyConflict represents an error which means another member already places its replicas with the
+	// same hash key, see HashKeyer.
+	ErrHashKeyConflict = errors.New("hash key is used by another member")
+
+	// ErrEmptyMemberName represents an error which means the String() of a member is empty. The name is the
+	// key of the member in the ring, so the empty names would collide.
+	ErrEmptyMemberName = errors.New("member name cannot be empty")
+
+	// ErrMemberConflict represents an error which means a member with the same name but a different weight
+	// is already in the ring. Use UpdateWeight or AddOrUpdate to change the weight of a member.
+	ErrMemberConflict = errors.New("member is already in the ring with a different weight")
+
+	// ErrInvalidPartitionID represents an error which means a partition ID is out of [0, PartitionCount).
+	ErrInvalidPartitionID = errors.New("partition ID out of range")
+)
+
+// DistributionError is returned when the partitions cannot be distributed among the members. It describes
+// the state of the ring when the given partition didn't fit any member. errors.Is(err, ErrNotEnoughRoom)
+// reports true for it.
+type DistributionError struct {
+	PartitionID     int
+	PartitionWeight float64
+	AverageLoad     float64
+	TotalWeight     int
+	PartitionCount  int
+	RingSize        int
+}
+
+func (e *DistributionError) Error() string {
+	return fmt.Sprintf("%v: partition %d (weight %v) doesn't fit, average load: %v, total weight: %d, "+
+		"partition count: %d, ring size: %d", ErrNotEnoughRoom, e.PartitionID, e.PartitionWeight,
+		e.AverageLoad, e.TotalWeight, e.PartitionCount, e.RingSize)
+}
+
+// Unwrap returns ErrNotEnoughRoom.
+func (e *DistributionError) Unwrap() error {
+	return ErrNotEnoughRoom
+}
+
+// notEnoughRoom returns a DistributionError for the given partition.
+func (c *WeightedConsistent) notEnoughRoom(partID int, avgLoad float64) error {
+	return &DistributionError{
+		PartitionID:     partID,
+		PartitionWeight: c.partitionWeight(partID),
+		AverageLoad:     avgLoad,
+		TotalWeight:     c.totalWeight,
+		PartitionCount:  int(c.partitionCount),
+		RingSize:        len(c.sortedSet),
+	}
+}
+
 // WeightedMember interface represents a weighted member in consistent hash ring.
 type WeightedMember interface {
 	Member
 	Weight() int
 }
 
+// MaxLoader is an optional interface of WeightedMember for the members with a hard capacity. MaxLoad returns
+// the maximum load of the member, which is the number of its partitions unless PartitionWeightFunc is set.
+// It caps the load on top of the bounded loads, the partitions spill to the next members when it's reached.
+type MaxLoader interface {
+	MaxLoad() int
+}
+
+// HashKeyer is an optional interface of WeightedMember for the members whose display name may change. HashKey
+// returns a stable identifier, e.g. a UUID, which is hashed to place the replicas on the ring instead of
+// String(). String() is still the name of the member in the ring, so the names must be unique as well.
+// Two members with the same hash key would share their positions, so it's rejected with ErrHashKeyConflict,
+// like a member whose HashKey equals the name of a member without one.
+type HashKeyer interface {
+	HashKey() []byte
+}
+
+// memberHashKey returns the key which places the replicas of the member on the ring.
+func memberHashKey(member WeightedMember) string {
+	if hk, ok := member.(HashKeyer); ok {
+		return string(hk.HashKey())
+	}
+	return member.String()
+}
+
+// MetricsObserver receives metrics about the ring, e.g. to export them to a monitoring system.
+// Its methods are called synchronously while the write lock is held, so they must be fast and
+// must not call the methods of the ring.
+type MetricsObserver interface {
+	// ObserveRedistribution is called after every redistribution of the partitions with its duration
+	// and the number of partitions whose owner changed.
+	ObserveRedistribution(duration time.Duration, partitionsMoved int)
+
+	// ObserveMemberCount is called after every redistribution with the number of members.
+	ObserveMemberCount(n int)
+}
+
+type nopMetricsObserver struct{}
+
+func (nopMetricsObserver) ObserveRedistribution(time.Duration, int) {}
+
+func (nopMetricsObserver) ObserveMemberCount(int) {}
+
 // WeightedConfig represents a structure to control weighted consistent package.
 type WeightedConfig struct {
 	// Hasher is responsible for generating unsigned, 64-bit hash of provided byte slice.
+	// DefaultHasher is used if it's nil.
 	Hasher Hasher
 
 	// Keys are distributed among partitions. Prime numbers are good to
 	// distribute keys uniformly. Select a big PartitionCount if you have
-	// too many keys.
+	// too many keys. If it's 0, SuggestPartitionCount picks one for the
+	// initial members.
 	PartitionCount int
 
 	// Base replication factor. Members will have replicas = ReplicationFactor * Weight
 	ReplicationFactor int
 
 	// Load is used to calculate average load. See the code, the paper and Google's blog post to learn about it.
+	// It must be greater than 1 unless DisableBoundedLoad is set: a member may take at most Load times its
+	// fair share of the partitions, so a Load of 1 leaves no room when the partition count isn't a multiple
+	// of the total weight. DefaultLoad is used if it's 0.
 	Load float64
+
+	// ReplicaKeyFunc builds the key which is hashed to place the idx-th replica of a member on the ring.
+	// It's optional, see Config.ReplicaKeyFunc for the default.
+	ReplicaKeyFunc func(name string, idx int) []byte
+
+	// PartitionKeyFunc builds the key which is hashed to place a partition on the ring. It's optional, the
+	// default is the partition ID as a little-endian uint64. Setting it allows to place the partitions the
+	// same way as rings built by other implementations. The Seed, if any, is prepended to the key like the
+	// keys of ReplicaKeyFunc.
+	PartitionKeyFunc func(partID int) []byte
+
+	// Seed is mixed into the hashes of the replicas and the partition IDs. See Config.Seed.
+	Seed uint64
+
+	// ZoneFunc returns the zone of a member. It's optional and only used by GetClosestNDistinctZones.
+	ZoneFunc func(name string) string
+
+	// AntiAffinityFunc returns the anti-affinity group of a member, e.g. the physical host of a logical
+	// member. It's optional. If it's set, GetClosestN, GetClosestNForPartition, GetClosestNExcludingOwner,
+	// GetClosestNFiltered, ReplicaSets and SecondaryOwner skip the members whose group is already in the
+	// result, so no two replicas of a partition are placed in the same group, and the GetClosestN variants
+	// return ErrInsufficientMemberCount if there are fewer than N groups. The owner of the partition always
+	// comes first. The partition owners and the backups of ReplicaSetSize aren't affected.
+	AntiAffinityFunc func(name string) string
+
+	// DisableBoundedLoad assigns every partition to the owner of its first successor on the ring regardless
+	// of the loads, which is the classic consistent hashing. The loads are only balanced by the replica counts
+	// of the members, so there is no balance guarantee, but the partitions always fit and ErrNotEnoughRoom
+	// is never returned. Load is ignored.
+	DisableBoundedLoad bool
+
+	// MinimalDisruption makes Add, AddMany and weight increases move as few partitions as possible: only
+	// the partitions which move to the added members, up to their expected loads, change their owners.
+	// Without it, every partition whose ring walk crosses an added member is redistributed, which may
+	// move partitions between the existing members as well.
+	MinimalDisruption bool
+
+	// StableTieBreak assigns every partition which has to be (re)distributed to the member with the most room
+	// left, which is its expected load minus its current load, and breaks ties by preferring the smallest
+	// member name instead of the ring order. The partition table then only depends on the members, their
+	// weights and the order of the mutations, not on the positions the Hasher gives them on the ring, so
+	// rings with different Hashers agree on the owner of every partition ID. Keys are still mapped to
+	// partition IDs by the Hasher, so a key is only owned by the same member if both rings map it to the
+	// same partition. When members are added, they take over the partitions which the existing members own
+	// beyond their fair shares. MinimalDisruption is ignored and it has no effect if DisableBoundedLoad is set.
+	StableTieBreak bool
+
+	// PartitionWeightFunc returns the weight of a partition, e.g. its size. The loads of the members are the
+	// sums of the weights of their partitions, so the bounded loads balance the partition weights instead of
+	// the partition counts. It's optional, every partition weighs 1 if it's not set. It's called for every
+	// partition when the ring is created or the partition count changes, the weights must not change later.
+	PartitionWeightFunc func(partID int) float64
+
+	// MaxReplicasPerMember caps ReplicationFactor * weight, the number of ring positions of a single member.
+	// Adding a member or raising a weight beyond it fails with ErrTooManyReplicas instead of allocating
+	// the positions. 0 means no limit.
+	MaxReplicasPerMember int
+
+	// MinReplicasPerMember is the minimum number of ring positions of a member regardless of its weight:
+	// a member gets max(ReplicationFactor * weight, MinReplicasPerMember) positions. It spreads the
+	// partitions of the light members over the ring instead of a few long arcs. The positions of the
+	// members below the minimum are no longer proportional to their weights, but the bounded loads still
+	// are, so they cannot take more than their shares of the partitions unless DisableBoundedLoad is set.
+	// It counts towards MaxReplicasPerMember. 0 means no minimum.
+	MinReplicasPerMember int
+
+	// TrackHistory is the number of recent owners kept for every partition, see PartitionHistory.
+	// 0 disables the history.
+	TrackHistory int
+
+	// ReplicaSetSize is the number of members every partition is stored on, the owner included. If it's
+	// greater than 1, every partition is also assigned ReplicaSetSize-1 backups, the next distinct members
+	// with some room on the ring after the owner, see GetReplicaSet. The loads then count the backups as
+	// well, so the bounded loads balance the replicas instead of the owned partitions, and the sum of the
+	// loads is ReplicaSetSize times the partition count. It's capped by the number of members. The
+	// partitions are distributed from scratch on every change of the ring, MinimalDisruption is ignored.
+	ReplicaSetSize int
+
+	// MetricsObserver is notified after every redistribution. It's optional.
+	MetricsObserver MetricsObserver
+
+	// OnDistributeFailure is called with the ID of a partition which doesn't fit any member instead of failing
+	// the distribution with ErrNotEnoughRoom, so the constructors and the mutations which panic on that error
+	// don't. The partition is left without an owner: GetPartitionOwner and LocateKey return nil for it, until
+	// a later change of the ring makes room for it. If the backups of a partition don't fit, see
+	// ReplicaSetSize, it's called as well and the partition keeps the backups which fit. It's called with the
+	// lock of the ring held, so it must not call the methods of the ring. DryRunDistribute ignores it.
+	OnDistributeFailure func(partID int)
 }
 
 // WeightedConsistent holds the information about the weighted members of the consistent hash circle.
+//
+// An empty ring, without members, is valid: the partitions exist but have no owners. The lookups of
+// a single member, e.g. LocateKey and GetPartitionOwner, return nil, the methods returning collections
+// return empty ones, AverageLoad and the other load statistics return 0, and the methods which need
+// members to pick from, GetClosestN and its variants and ReplicaSets, return ErrEmptyRing. ErrInvalidCount
+// takes precedence over ErrEmptyRing.
 type WeightedConsistent struct {
+	// counters is the first field to keep the 64-bit counters aligned for the atomic operations
+	// on 32-bit platforms.
+	counters mutationCounters
+
 	mu sync.RWMutex
 
-	config         WeightedConfig
-	hasher         Hasher
-	sortedSet      []uint64
+	config    WeightedConfig
+	hasher    Hasher
+	sortedSet []uint64
+	// partitionCount is only changed with the write lock held and published with the partition table.
+	// The lock-free readers must read it from loadTable() instead.
+	partitionCount uint64
+	// previousPartitionCount is the partition count before the last SetPartitionCount, see ParentPartition.
+	previousPartitionCount uint64
+	// partitionHashes holds the hashes of the partition IDs, which don't change until the partition count does.
+	partitionHashes []uint64
+	// partitionWeights holds the weights of the partitions if PartitionWeightFunc is set and
+	// partitionLoad is the sum of the partition weights.
+	partitionWeights []float64
+	partitionLoad    float64
+	loads            map[string]float64
+	members          map[string]*WeightedMember
+	weights          map[string]int
+	totalWeight      int
+	partitions       map[int]*WeightedMember
+	ring             map[uint64]*WeightedMember
+
+	onPartitionMoved func(partID int, from, to string)
+	// history holds the recent owners of the partitions, oldest first, if TrackHistory is set.
+	history map[int][]string
+	// backups holds the backups of every partition if ReplicaSetSize is greater than 1.
+	backups map[int][]*WeightedMember
+	// draining holds the weights of the draining members to restore, see SetDraining.
+	draining map[string]int
+	// floatScale is the replication factor which the float weights are scaled by, see NewWeightedFloat.
+	floatScale int
+	// version is incremented after every change of the ring, see Version.
+	version uint64
+
+	// table holds the latest *ownerTable. It's replaced, never modified, after every redistribution.
+	table atomic.Value
+}
+
+// mutationCounters holds the cumulative counters of the changes of a ring. They're only incremented with
+// the write lock held but read without it, so they're accessed atomically.
+type mutationCounters struct {
+	adds            uint64
+	removes         uint64
+	redistributions uint64
+	moved           uint64
+}
+
+// ownerTable is an immutable copy of the partition table. FindPartitionID, GetPartitionOwner and LocateKey
+// read the latest one without taking the lock.
+type ownerTable struct {
 	partitionCount uint64
-	loads          map[string]float64
-	members        map[string]*WeightedMember
-	weights        map[string]int
-	totalWeight    int
-	partitions     map[int]*WeightedMember
-	ring           map[uint64]*WeightedMember
+	owners         []WeightedMember
+}
+
+// publish builds a new ownerTable from the partition table and makes it visible to the lock-free readers.
+// It must be called with the write lock held after c.partitions or c.partitionCount is replaced.
+func (c *WeightedConsistent) publish() {
+	t := &ownerTable{
+		partitionCount: c.partitionCount,
+		owners:         make([]WeightedMember, c.partitionCount),
+	}
+	for partID, member := range c.partitions {
+		if partID >= 0 && partID < len(t.owners) {
+			t.owners[partID] = *member
+		}
+	}
+	c.table.Store(t)
+}
+
+func (c *WeightedConsistent) loadTable() *ownerTable {
+	return c.table.Load().(*ownerTable)
+}
+
+// SuggestPartitionCount returns a partition count for a cluster of memberCount members and the given load factor.
+//
+// Every member may own up to ceil(PartitionCount / memberCount * load) partitions, so the headroom of a member
+// over its fair share is PartitionCount / memberCount * (load - 1) partitions. If the headroom is only a few
+// partitions, rounding dominates and the ring runs out of room quickly, so the suggestion leaves 10 partitions
+// of headroom per member: the smallest prime which is at least 10 * memberCount / (load - 1), but never less
+// than DefaultPartitionCount. A load factor which is not greater than 1 is replaced with DefaultLoad.
+func SuggestPartitionCount(memberCount int, load float64) int {
+	if load <= 1 {
+		load = DefaultLoad
+	}
+	n := int(math.Ceil(10 * float64(memberCount) / (load - 1)))
+	if n < DefaultPartitionCount {
+		n = DefaultPartitionCount
+	}
+	for !isPrime(n) {
+		n++
+	}
+	return n
+}
+
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
 }
 
-// NewWeighted creates and returns a new WeightedConsistent object.
+// NewWeighted creates and returns a new WeightedConsistent object. DefaultHasher is used if config.Hasher is nil.
+// It panics if the partitions cannot be distributed among the members, use NewWeightedChecked to get an error instead.
+// A name repeated with the same weight is only added once, but a name repeated with a different weight panics with
+// ErrMemberConflict; earlier versions silently kept the first one.
 func NewWeighted(members []WeightedMember, config WeightedConfig) *WeightedConsistent {
-	if config.Hasher == nil {
-		panic("Hasher cannot be nil")
+	c, err := NewWeightedChecked(members, config)
+	if err != nil {
+		panic(err)
 	}
-	if config.PartitionCount == 0 {
-		config.PartitionCount = DefaultPartitionCount
+	return c
+}
+
+// NewWeightedChecked creates and returns a new WeightedConsistent object. DefaultHasher is used if config.Hasher
+// is nil. It returns ErrInvalidLoad if config.Load isn't greater than 1, ErrNotEnoughRoom if the partitions
+// cannot be distributed among the members, ErrTooManyReplicas if a member exceeds MaxReplicasPerMember,
+// ErrHashKeyConflict if two members have the same hash key, ErrEmptyMemberName if a name is empty and
+// ErrMemberConflict if a name is repeated with different weights. The repeated members are only added once.
+func NewWeightedChecked(members []WeightedMember, config WeightedConfig) (*WeightedConsistent, error) {
+	return NewWeightedContext(context.Background(), members, config)
+}
+
+// NewWeightedContext is like NewWeightedChecked but stops building the ring and returns ctx.Err() if ctx
+// is cancelled while the members are placed on the ring or the partitions are distributed.
+func NewWeightedContext(ctx context.Context, members []WeightedMember, config WeightedConfig) (*WeightedConsistent, error) {
+	if config.Hasher == nil {
+		config.Hasher = DefaultHasher{}
 	}
 	if config.ReplicationFactor == 0 {
 		config.ReplicationFactor = DefaultReplicationFactor
@@ -87,6 +421,21 @@ func NewWeighted(members []WeightedMember, config WeightedConfig) *WeightedConsi
 	if config.Load == 0 {
 		config.Load = DefaultLoad
 	}
+	if config.Load <= 1 && !config.DisableBoundedLoad {
+		return nil, ErrInvalidLoad
+	}
+	if config.PartitionCount == 0 {
+		config.PartitionCount = SuggestPartitionCount(len(members), config.Load)
+	}
+	if config.ReplicaKeyFunc == nil {
+		config.ReplicaKeyFunc = replicaKey
+	}
+	if config.PartitionKeyFunc == nil {
+		config.PartitionKeyFunc = partitionIDKey
+	}
+	if config.MetricsObserver == nil {
+		config.MetricsObserver = nopMetricsObserver{}
+	}
 
 	c := &WeightedConsistent{
 		config:         config,
@@ -95,293 +444,2050 @@ func NewWeighted(members []WeightedMember, config WeightedConfig) *WeightedConsi
 		partitionCount: uint64(config.PartitionCount),
 		ring:           make(map[uint64]*WeightedMember),
 	}
-
 	c.hasher = config.Hasher
-	for _, member := range members {
+	c.initPartitions()
+	c.publish()
+
+	for i, member := range members {
+		if err := checkContext(ctx, i); err != nil {
+			return nil, err
+		}
+		if exists, err := c.checkExisting(member); err != nil {
+			return nil, err
+		} else if exists {
+			continue
+		}
+		if err := c.checkMember(member); err != nil {
+			return nil, err
+		}
 		c.add(member)
 	}
-	if members != nil {
-		c.distributePartitions()
+	if len(c.members) > 0 {
+		if err := c.distributePartitions(ctx); err != nil {
+			return nil, err
+		}
 	}
-	return c
+	return c, nil
 }
 
-// GetMembers returns a thread-safe copy of members. If there are no members, it returns an empty slice of WeightedMember.
-func (c *WeightedConsistent) GetMembers() []WeightedMember {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// contextCheckInterval is the number of iterations between two checks of the context in the long loops.
+const contextCheckInterval = 256
 
-	// Create a thread-safe copy of member list.
-	members := make([]WeightedMember, 0, len(c.members))
-	for _, member := range c.members {
-		members = append(members, *member)
+// checkContext returns ctx.Err() every contextCheckInterval iterations and nil otherwise.
+func checkContext(ctx context.Context, i int) error {
+	if i%contextCheckInterval != 0 {
+		return nil
 	}
-	return members
+	return ctx.Err()
 }
 
-// AverageLoad exposes the current average load considering weights.
-func (c *WeightedConsistent) AverageLoad() float64 {
+// Clone returns a deep copy of the ring, which can be mutated without affecting the original. Only the Hasher
+// and the optional functions of the config are shared. The OnPartitionMoved callback and the counters, see Adds,
+// aren't copied.
+func (c *WeightedConsistent) Clone() *WeightedConsistent {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return c.averageLoad()
+	return c.clone()
 }
 
-func (c *WeightedConsistent) averageLoad() float64 {
-	if len(c.members) == 0 || c.totalWeight == 0 {
-		return 0
+// clone returns a deep copy of the ring, see Clone. It's not thread-safe.
+func (c *WeightedConsistent) clone() *WeightedConsistent {
+	clone := &WeightedConsistent{
+		config:                 c.config,
+		hasher:                 c.hasher,
+		sortedSet:              make([]uint64, len(c.sortedSet)),
+		partitionCount:         c.partitionCount,
+		previousPartitionCount: c.previousPartitionCount,
+		partitionHashes:        c.partitionHashes,
+		partitionWeights:       c.partitionWeights,
+		partitionLoad:          c.partitionLoad,
+		loads:                  make(map[string]float64, len(c.loads)),
+		members:                make(map[string]*WeightedMember, len(c.members)),
+		weights:                make(map[string]int, len(c.weights)),
+		totalWeight:            c.totalWeight,
+		partitions:             make(map[int]*WeightedMember, len(c.partitions)),
+		ring:                   make(map[uint64]*WeightedMember, len(c.ring)),
+		floatScale:             c.floatScale,
+		version:                c.version,
 	}
-
-	avgLoad := float64(c.partitionCount)/float64(c.totalWeight) * c.config.Load
-	return math.Ceil(avgLoad)
-}
-
-func (c *WeightedConsistent) distributeWithLoad(partID, idx int, partitions map[int]*WeightedMember, loads map[string]float64) {
-	avgLoad := c.averageLoad()
-	var count int
-	for {
-		count++
-		if count >= len(c.sortedSet) {
-			// User needs to decrease partition count, increase member count or increase load factor.
-			panic("not enough room to distribute partitions")
-		}
-		i := c.sortedSet[idx]
-		member := *c.ring[i]
-		memberWeight := float64(c.weights[member.String()])
-		expectedLoad := avgLoad * memberWeight
-		load := loads[member.String()]
-		if load+1 <= expectedLoad {
-			partitions[partID] = &member
-			loads[member.String()]++
-			return
+	copy(clone.sortedSet, c.sortedSet)
+	for name, member := range c.members {
+		m := *member
+		clone.members[name] = &m
+	}
+	for h, member := range c.ring {
+		clone.ring[h] = clone.members[(*member).String()]
+	}
+	for partID, member := range c.partitions {
+		clone.partitions[partID] = clone.members[(*member).String()]
+	}
+	for name, weight := range c.weights {
+		clone.weights[name] = weight
+	}
+	for name, load := range c.loads {
+		clone.loads[name] = load
+	}
+	if c.history != nil {
+		clone.history = make(map[int][]string, len(c.history))
+		for partID, history := range c.history {
+			clone.history[partID] = append([]string(nil), history...)
 		}
-		idx++
-		if idx >= len(c.sortedSet) {
-			idx = 0
+	}
+	if c.backups != nil {
+		clone.backups = make(map[int][]*WeightedMember, len(c.backups))
+		for partID, set := range c.backups {
+			backups := make([]*WeightedMember, len(set))
+			for i, member := range set {
+				backups[i] = clone.members[(*member).String()]
+			}
+			clone.backups[partID] = backups
 		}
 	}
-}
-
-func (c *WeightedConsistent) distributePartitions() {
-	loads := make(map[string]float64)
-	partitions := make(map[int]*WeightedMember)
-
-	bs := make([]byte, 8)
-	for partID := uint64(0); partID < c.partitionCount; partID++ {
-		binary.LittleEndian.PutUint64(bs, partID)
-		key := c.hasher.Sum64(bs)
-		idx := sort.Search(len(c.sortedSet), func(i int) bool {
-			return c.sortedSet[i] >= key
-		})
-		if idx >= len(c.sortedSet) {
-			idx = 0
+	if c.draining != nil {
+		clone.draining = make(map[string]int, len(c.draining))
+		for name, weight := range c.draining {
+			clone.draining[name] = weight
 		}
-		c.distributeWithLoad(int(partID), idx, partitions, loads)
 	}
-	c.partitions = partitions
-	c.loads = loads
+	clone.publish()
+	return clone
 }
 
-func (c *WeightedConsistent) add(member WeightedMember) {
-	weight := member.Weight()
-	if weight <= 0 {
-		weight = 1 // Ensure minimum weight of 1
+// Equal reports whether both rings have the same members with the same weights, the same partition count
+// and the same owner for every partition. The hashers and the other config fields aren't compared. It's
+// useful to check that the nodes of a cluster converged to the same view of the ring.
+func (c *WeightedConsistent) Equal(other *WeightedConsistent) bool {
+	if c == other {
+		return true
 	}
-
-	// Calculate replicas based on weight
-	replicas := c.config.ReplicationFactor * weight
-
-	for i := 0; i < replicas; i++ {
-		key := []byte(fmt.Sprintf("%s%d", member.String(), i))
-		h := c.hasher.Sum64(key)
-		c.ring[h] = &member
-		c.sortedSet = append(c.sortedSet, h)
+	if other == nil {
+		return false
 	}
-	// sort hashes ascendingly
-	sort.Slice(c.sortedSet, func(i int, j int) bool {
-		return c.sortedSet[i] < c.sortedSet[j]
-	})
 
-	// Store member and weight information
-	c.members[member.String()] = &member
-	c.weights[member.String()] = weight
-	c.totalWeight += weight
-}
+	// Copy the view of the other ring first, so both locks are never held at the same time.
+	other.mu.RLock()
+	weights := make(map[string]int, len(other.weights))
+	for name, weight := range other.weights {
+		weights[name] = weight
+	}
+	partitionCount := other.partitionCount
+	owners := make(map[int]string, len(other.partitions))
+	for partID, member := range other.partitions {
+		owners[partID] = (*member).String()
+	}
+	other.mu.RUnlock()
 
-// Add adds a new weighted member to the consistent hash circle.
-func (c *WeightedConsistent) Add(member WeightedMember) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	if _, ok := c.members[member.String()]; ok {
-		// We already have this member. Quit immediately.
-		return
+	if c.partitionCount != partitionCount || len(c.weights) != len(weights) || len(c.partitions) != len(owners) {
+		return false
 	}
-	c.add(member)
-	c.distributePartitions()
-}
-
-func (c *WeightedConsistent) delSlice(val uint64) {
-	for i := 0; i < len(c.sortedSet); i++ {
-		if c.sortedSet[i] == val {
-			c.sortedSet = append(c.sortedSet[:i], c.sortedSet[i+1:]...)
-			break
+	for name, weight := range c.weights {
+		if w, ok := weights[name]; !ok || w != weight {
+			return false
+		}
+	}
+	for partID, member := range c.partitions {
+		if owner, ok := owners[partID]; !ok || owner != (*member).String() {
+			return false
 		}
 	}
+	return true
 }
 
-// Remove removes a weighted member from the consistent hash circle.
-func (c *WeightedConsistent) Remove(name string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// GetMembers returns a thread-safe copy of members sorted by name. If there are no members, it returns an empty
+// slice of WeightedMember.
+func (c *WeightedConsistent) GetMembers() []WeightedMember {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	_, ok := c.members[name]
-	if !ok {
-		// There is no member with that name. Quit immediately.
-		return
+	// Create a thread-safe copy of member list.
+	members := make([]WeightedMember, 0, len(c.members))
+	for _, name := range c.sortedNames() {
+		members = append(members, *c.members[name])
 	}
+	return members
+}
 
-	weight := c.weights[name]
-	replicas := c.config.ReplicationFactor * weight
-
-	for i := 0; i < replicas; i++ {
-		key := []byte(fmt.Sprintf("%s%d", name, i))
-		h := c.hasher.Sum64(key)
-		delete(c.ring, h)
-		c.delSlice(h)
+// sortedNames returns the sorted names of the members. The members are kept in maps, so the code whose result
+// depends on the order of the members iterates them in this order instead. It's not thread-safe.
+func (c *WeightedConsistent) sortedNames() []string {
+	names := make([]string, 0, len(c.members))
+	for name := range c.members {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	delete(c.members, name)
-	c.totalWeight -= c.weights[name]
-	delete(c.weights, name)
+// MembersCount returns the number of members without copying the member list.
+func (c *WeightedConsistent) MembersCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	if len(c.members) == 0 {
-		// consistent hash ring is empty now. Reset the partition table.
-		c.partitions = make(map[int]*WeightedMember)
-		c.totalWeight = 0
-		return
-	}
-	c.distributePartitions()
+	return len(c.members)
 }
 
-// LoadDistribution exposes load distribution of weighted members.
-func (c *WeightedConsistent) LoadDistribution() map[string]float64 {
+// RingSize returns the number of replicas (virtual nodes) on the ring.
+func (c *WeightedConsistent) RingSize() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// Create a thread-safe copy
-	res := make(map[string]float64)
-	for member, load := range c.loads {
-		res[member] = load
-	}
-	return res
+	return len(c.sortedSet)
 }
 
-// WeightDistribution exposes weight distribution of members.
-func (c *WeightedConsistent) WeightDistribution() map[string]int {
+// ReplicaCount returns the number of replicas of the given member on the ring, which is ReplicationFactor
+// multiplied by its weight, or MinReplicasPerMember if it's greater. It returns 0 for unknown members.
+func (c *WeightedConsistent) ReplicaCount(name string) int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// Create a thread-safe copy
-	res := make(map[string]int)
-	for member, weight := range c.weights {
-		res[member] = weight
+	if _, ok := c.members[name]; !ok {
+		return 0
 	}
-	return res
+	return c.replicas(c.weights[name])
 }
 
-// FindPartitionID returns partition id for given key.
-func (c *WeightedConsistent) FindPartitionID(key []byte) int {
-	hkey := c.hasher.Sum64(key)
-	return int(hkey % c.partitionCount)
+// MemberPositions returns the sorted positions of the replicas of the given member on the ring. It returns
+// nil for unknown members.
+func (c *WeightedConsistent) MemberPositions(name string) []uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.members[name]; !ok {
+		return nil
+	}
+	positions := make([]uint64, 0, c.replicas(c.weights[name]))
+	for _, h := range c.sortedSet {
+		if (*c.ring[h]).String() == name {
+			positions = append(positions, h)
+		}
+	}
+	return positions
 }
 
-// GetPartitionOwner returns the owner of the given partition.
-func (c *WeightedConsistent) GetPartitionOwner(partID int) WeightedMember {
+// Version returns a number which is incremented after every change of the ring: adding or removing
+// members, changing weights or the partition count. A call which doesn't change anything, e.g. adding
+// a member which is already in the ring, doesn't increment it. The version is local to the ring, use
+// Fingerprint to compare rings across processes.
+func (c *WeightedConsistent) Version() uint64 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return c.getPartitionOwner(partID)
+	return c.version
 }
 
-// getPartitionOwner returns the owner of the given partition. It's not thread-safe.
-func (c *WeightedConsistent) getPartitionOwner(partID int) WeightedMember {
-	member, ok := c.partitions[partID]
-	if !ok {
-		return nil
+// Fingerprint returns a hash of the member names and their weights. The rings with the same members
+// and weights have the same fingerprint regardless of the order of the changes which led to them and
+// of the Hasher, so it's a cheap way to check that the nodes of a cluster converged to the same members.
+// Use Equal to compare the partition tables as well.
+func (c *WeightedConsistent) Fingerprint() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, name := range c.sortedNames() {
+		h.Write([]byte(name))
+		// The separator keeps a name from running into the weight of the previous member.
+		h.Write([]byte{0})
+		binary.LittleEndian.PutUint64(buf, uint64(c.weights[name]))
+		h.Write(buf)
 	}
-	// Create a thread-safe copy of member and return it.
-	return *member
+	return h.Sum64()
 }
 
-// LocateKey finds a home for given key considering member weights
-func (c *WeightedConsistent) LocateKey(key []byte) WeightedMember {
-	partID := c.FindPartitionID(key)
-	return c.GetPartitionOwner(partID)
+// Has reports whether a member with the given name is in the ring.
+func (c *WeightedConsistent) Has(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.members[name]
+	return ok
 }
 
-func (c *WeightedConsistent) getClosestN(partID, count int) ([]WeightedMember, error) {
+// AverageLoad exposes the current average load considering weights.
+func (c *WeightedConsistent) AverageLoad() float64 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	var res []WeightedMember
-	if count > len(c.members) {
-		return res, ErrInsufficientMemberCount
-	}
+	return c.averageLoad()
+}
 
-	var ownerKey uint64
-	owner := c.getPartitionOwner(partID)
-	// Hash and sort all the names.
-	var keys []uint64
-	kmems := make(map[uint64]*WeightedMember)
-	for name, member := range c.members {
-		key := c.hasher.Sum64([]byte(name))
-		if name == owner.String() {
-			ownerKey = key
-		}
-		keys = append(keys, key)
-		kmems[key] = member
+func (c *WeightedConsistent) averageLoad() float64 {
+	if len(c.members) == 0 || c.totalWeight == 0 {
+		return 0
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i] < keys[j]
-	})
 
-	// Find the key owner
-	idx := 0
-	for idx < len(keys) {
-		if keys[idx] == ownerKey {
-			key := keys[idx]
-			res = append(res, *kmems[key])
-			break
+	avgLoad := c.replicaShare() * c.config.Load
+	return math.Ceil(avgLoad)
+}
+
+// replicaShare returns the load per weight unit without the headroom of Load. A member cannot store
+// a partition more than once, so with ReplicaSetSize greater than 1 the heavy members may be capped at
+// partitionLoad. The load they cannot take is spread over the other members.
+func (c *WeightedConsistent) replicaShare() float64 {
+	remaining := c.partitionLoad * float64(c.replicaSetSize())
+	weight := float64(c.totalWeight)
+	share := remaining / weight
+	if c.replicaSetSize() == 1 {
+		return share
+	}
+
+	var capped map[string]struct{}
+	for {
+		changed := false
+		for name, w := range c.weights {
+			if _, ok := capped[name]; ok || share*float64(w) <= c.partitionLoad {
+				continue
+			}
+			if capped == nil {
+				capped = make(map[string]struct{})
+			}
+			capped[name] = struct{}{}
+			remaining -= c.partitionLoad
+			weight -= float64(w)
+			changed = true
+		}
+		if !changed || weight <= 0 {
+			return share
+		}
+		share = remaining / weight
+	}
+}
+
+// replicaSetSize returns the number of members every partition is stored on, see WeightedConfig.ReplicaSetSize.
+func (c *WeightedConsistent) replicaSetSize() int {
+	if c.config.ReplicaSetSize > len(c.members) {
+		return len(c.members)
+	}
+	if c.config.ReplicaSetSize < 1 {
+		return 1
+	}
+	return c.config.ReplicaSetSize
+}
+
+// capacity returns the maximum load of the given member: avgLoad multiplied by its weight, capped by its
+// MaxLoad if it implements MaxLoader. There is no limit but MaxLoad if DisableBoundedLoad is set.
+func (c *WeightedConsistent) capacity(name string, avgLoad float64) float64 {
+	limit := math.Inf(1)
+	if !c.config.DisableBoundedLoad {
+		limit = avgLoad * float64(c.weights[name])
+	}
+	if member, ok := c.members[name]; ok {
+		if m, ok := (*member).(MaxLoader); ok {
+			limit = math.Min(limit, float64(m.MaxLoad()))
+		}
+	}
+	return limit
+}
+
+// partitionIDKey is the default PartitionKeyFunc. With the seed prepended by seededKey, it's the same key
+// hashPartition hashes, so the weighted and the unweighted rings place the partitions the same way.
+func partitionIDKey(partID int) []byte {
+	bs := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bs, uint64(partID))
+	return bs
+}
+
+// initPartitions computes the hashes of the partitions and their weights with PartitionWeightFunc.
+// It must be called whenever partitionCount changes.
+func (c *WeightedConsistent) initPartitions() {
+	c.partitionHashes = make([]uint64, c.partitionCount)
+	for partID := range c.partitionHashes {
+		c.partitionHashes[partID] = c.hasher.Sum64(seededKey(c.config.Seed, c.config.PartitionKeyFunc(partID)))
+	}
+
+	if c.config.PartitionWeightFunc == nil {
+		c.partitionWeights = nil
+		c.partitionLoad = float64(c.partitionCount)
+		return
+	}
+	c.partitionWeights = make([]float64, c.partitionCount)
+	c.partitionLoad = 0
+	for partID := range c.partitionWeights {
+		c.partitionWeights[partID] = c.config.PartitionWeightFunc(partID)
+		c.partitionLoad += c.partitionWeights[partID]
+	}
+}
+
+// partitionWeight returns the weight of the given partition, see WeightedConfig.PartitionWeightFunc.
+func (c *WeightedConsistent) partitionWeight(partID int) float64 {
+	if c.partitionWeights == nil {
+		return 1
+	}
+	return c.partitionWeights[partID]
+}
+
+func (c *WeightedConsistent) distributeWithLoad(partID, idx int, partitions map[int]*WeightedMember, loads map[string]float64) error {
+	pw := c.partitionWeight(partID)
+	avgLoad := c.averageLoad()
+	if len(c.sortedSet) == 0 {
+		return c.notEnoughRoom(partID, avgLoad)
+	}
+	if c.config.DisableBoundedLoad {
+		member := *c.ring[c.sortedSet[idx]]
+		if loads[member.String()]+pw <= c.capacity(member.String(), avgLoad) {
+			partitions[partID] = &member
+			loads[member.String()] += pw
+			return nil
+		}
+		// The successor reached its MaxLoad, look for the next member with some room.
+	} else if c.config.StableTieBreak {
+		return c.distributeStable(partID, avgLoad, partitions, loads)
+	}
+
+	var count int
+	for {
+		count++
+		if count >= len(c.sortedSet) {
+			// User needs to decrease partition count, increase member count or increase load factor.
+			return c.notEnoughRoom(partID, avgLoad)
+		}
+		i := c.sortedSet[idx]
+		member := *c.ring[i]
+		load := loads[member.String()]
+		if load+pw <= c.capacity(member.String(), avgLoad) {
+			partitions[partID] = &member
+			loads[member.String()] += pw
+			return nil
+		}
+		idx++
+		if idx >= len(c.sortedSet) {
+			idx = 0
+		}
+	}
+}
+
+// distributeStable assigns the partition to the member with the most room left, preferring the smallest
+// name among the members with the same room. See WeightedConfig.StableTieBreak.
+func (c *WeightedConsistent) distributeStable(partID int, avgLoad float64, partitions map[int]*WeightedMember, loads map[string]float64) error {
+	pw := c.partitionWeight(partID)
+	var best string
+	var bestRoom float64
+	for name := range c.weights {
+		room := c.capacity(name, avgLoad) - loads[name]
+		if room < pw {
+			continue
+		}
+		if best == "" || room > bestRoom || (room == bestRoom && name < best) {
+			best, bestRoom = name, room
+		}
+	}
+	if best == "" {
+		// User needs to decrease partition count, increase member count or increase load factor.
+		return c.notEnoughRoom(partID, avgLoad)
+	}
+	partitions[partID] = c.members[best]
+	loads[best] += pw
+	return nil
+}
+
+// distributePartitions computes the partition table from scratch. The current table is left untouched
+// if it returns an error.
+func (c *WeightedConsistent) distributePartitions(ctx context.Context) error {
+	started := time.Now()
+	partitions, backups, loads, err := c.distribute(ctx, c.config.OnDistributeFailure)
+	if err != nil {
+		return err
+	}
+	c.backups = backups
+	c.setPartitions(partitions, loads, started)
+	return nil
+}
+
+// distribute computes a new partition table and the backups of the partitions, see ReplicaSetSize, from
+// scratch without modifying the ring. If onFailure isn't nil, the partitions which don't fit are passed to
+// it and left without owners, see WeightedConfig.OnDistributeFailure.
+func (c *WeightedConsistent) distribute(ctx context.Context, onFailure func(partID int)) (map[int]*WeightedMember, map[int][]*WeightedMember, map[string]float64, error) {
+	loads := make(map[string]float64)
+	partitions := make(map[int]*WeightedMember)
+	var backups map[int][]*WeightedMember
+	if c.replicaSetSize() > 1 {
+		backups = make(map[int][]*WeightedMember, c.partitionCount)
+	}
+
+	for partID := 0; partID < int(c.partitionCount); partID++ {
+		if err := checkContext(ctx, partID); err != nil {
+			return nil, nil, nil, err
+		}
+		if err := c.distributeWithLoad(partID, c.partitionIndex(partID), partitions, loads); err != nil {
+			if !leaveUnassigned(onFailure, partID, err) {
+				return nil, nil, nil, err
+			}
+			continue
+		}
+		if backups != nil {
+			if err := c.distributeBackups(partID, partitions, backups, loads); err != nil && !leaveUnassigned(onFailure, partID, err) {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	return partitions, backups, loads, nil
+}
+
+// distributeBackups assigns the backups of the partition: the next distinct members after the owner's
+// position on the ring which have room for the partition.
+func (c *WeightedConsistent) distributeBackups(partID int, partitions map[int]*WeightedMember, backups map[int][]*WeightedMember, loads map[string]float64) error {
+	pw := c.partitionWeight(partID)
+	avgLoad := c.averageLoad()
+	owner := (*partitions[partID]).String()
+	count := c.replicaSetSize() - 1
+
+	set := make([]*WeightedMember, 0, count)
+	c.walkMembersFrom(c.ownerIndex(partID, owner), make(map[string]struct{}), func(idx int, member WeightedMember) bool {
+		name := member.String()
+		if name != owner && loads[name]+pw <= c.capacity(name, avgLoad) {
+			set = append(set, c.members[name])
+			loads[name] += pw
+		}
+		return len(set) < count
+	})
+	backups[partID] = set
+	if len(set) < count {
+		return c.notEnoughRoom(partID, avgLoad)
+	}
+	return nil
+}
+
+// leaveUnassigned reports whether the distribution may go on after err, which is a DistributionError if
+// the partition didn't fit, and passes the partition to onFailure if so. See WeightedConfig.OnDistributeFailure.
+func leaveUnassigned(onFailure func(partID int), partID int, err error) bool {
+	var derr *DistributionError
+	if onFailure == nil || !errors.As(err, &derr) {
+		return false
+	}
+	onFailure(partID)
+	return true
+}
+
+// DryRunDistribute distributes all the partitions from scratch into a separate table, without modifying
+// the ring, and returns the error the distribution would fail with. It's a DistributionError describing
+// the partition which didn't fit. It's useful to check whether the members, e.g. after their MaxLoad
+// changed, still have room for all the partitions.
+func (c *WeightedConsistent) DryRunDistribute() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.members) == 0 {
+		return nil
+	}
+	_, _, _, err := c.distribute(context.Background(), nil)
+	return err
+}
+
+// setPartitions replaces the partition table, notifies the OnPartitionMoved callback about every partition
+// whose owner changed and reports the redistribution which started at the given time to the MetricsObserver.
+func (c *WeightedConsistent) setPartitions(partitions map[int]*WeightedMember, loads map[string]float64, started time.Time) {
+	old := c.partitions
+	c.partitions = partitions
+	c.loads = loads
+	if len(partitions) == 0 || c.replicaSetSize() <= 1 {
+		// The backups are only assigned by distributePartitions.
+		c.backups = nil
+	}
+	c.version++
+	c.publish()
+
+	owner := func(table map[int]*WeightedMember, partID int) string {
+		if member, ok := table[partID]; ok {
+			return (*member).String()
+		}
+		return ""
+	}
+	count := int(c.partitionCount)
+	if len(old) > count {
+		// The partition count shrank, the removed partitions lost their owners.
+		count = len(old)
+	}
+	var moved int
+	for partID := 0; partID < count; partID++ {
+		from, to := owner(old, partID), owner(partitions, partID)
+		if from == to {
+			continue
+		}
+		moved++
+		if c.config.TrackHistory > 0 && to != "" {
+			c.recordOwner(partID, to)
+		}
+		if c.onPartitionMoved != nil {
+			c.onPartitionMoved(partID, from, to)
+		}
+	}
+	atomic.AddUint64(&c.counters.redistributions, 1)
+	atomic.AddUint64(&c.counters.moved, uint64(moved))
+	c.config.MetricsObserver.ObserveRedistribution(time.Since(started), moved)
+	c.config.MetricsObserver.ObserveMemberCount(len(c.members))
+}
+
+// Adds returns the number of members added to the ring since it was created, not counting the initial
+// members. Adding a member which is already in the ring doesn't count.
+func (c *WeightedConsistent) Adds() uint64 {
+	return atomic.LoadUint64(&c.counters.adds)
+}
+
+// Removes returns the number of members removed from the ring since it was created.
+func (c *WeightedConsistent) Removes() uint64 {
+	return atomic.LoadUint64(&c.counters.removes)
+}
+
+// Redistributions returns the number of times the partitions were distributed since the ring was created,
+// including the initial distribution.
+func (c *WeightedConsistent) Redistributions() uint64 {
+	return atomic.LoadUint64(&c.counters.redistributions)
+}
+
+// PartitionsMoved returns the total number of partitions which changed their owners since the ring was created.
+func (c *WeightedConsistent) PartitionsMoved() uint64 {
+	return atomic.LoadUint64(&c.counters.moved)
+}
+
+// recordOwner appends the new owner of the partition to its history and drops the oldest owners
+// beyond TrackHistory.
+func (c *WeightedConsistent) recordOwner(partID int, owner string) {
+	if c.history == nil {
+		c.history = make(map[int][]string)
+	}
+	history := append(c.history[partID], owner)
+	if len(history) > c.config.TrackHistory {
+		history = append(history[:0:0], history[len(history)-c.config.TrackHistory:]...)
+	}
+	c.history[partID] = history
+}
+
+// PartitionHistory returns the last owners of the given partition, oldest first. The latest one is the
+// current owner. A partition gets a new entry whenever a redistribution gives it a new owner, at most
+// WeightedConfig.TrackHistory entries are kept. It returns nil if the history is disabled or the
+// partition never had an owner.
+func (c *WeightedConsistent) PartitionHistory(partID int) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	history, ok := c.history[partID]
+	if !ok {
+		return nil
+	}
+	res := make([]string, len(history))
+	copy(res, history)
+	return res
+}
+
+// OnPartitionMoved registers a callback which is invoked after every redistribution for each partition
+// whose owner changed. from is empty if the partition had no owner and to is empty if the ring became
+// empty. Registering a new callback replaces the previous one and nil unregisters it.
+//
+// The callback is invoked synchronously while the write lock is held, so it must not call the methods
+// of the ring.
+func (c *WeightedConsistent) OnPartitionMoved(fn func(partID int, from, to string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onPartitionMoved = fn
+}
+
+// partitionIndex returns the index of the first ring position at or after the hash of the given partition.
+func (c *WeightedConsistent) partitionIndex(partID int) int {
+	key := c.partitionHashes[partID]
+	idx := sort.Search(len(c.sortedSet), func(i int) bool {
+		return c.sortedSet[i] >= key
+	})
+	if idx >= len(c.sortedSet) {
+		idx = 0
+	}
+	return idx
+}
+
+// passesAdded reports whether walking the ring from idx towards the given owner
+// crosses a position of one of the added members first.
+func (c *WeightedConsistent) passesAdded(idx int, owner string, added map[string]struct{}) bool {
+	for count := 0; count < len(c.sortedSet); count++ {
+		name := (*c.ring[c.sortedSet[idx]]).String()
+		if name == owner {
+			return false
+		}
+		if _, ok := added[name]; ok {
+			return true
+		}
+		idx++
+		if idx >= len(c.sortedSet) {
+			idx = 0
+		}
+	}
+	return false
+}
+
+// addedSuccessor returns the first member of the added ones which is reached walking the ring from idx and
+// still has room for one more partition, or nil if there is none.
+func (c *WeightedConsistent) addedSuccessor(idx int, added map[string]struct{}, fits func(name string) bool) *WeightedMember {
+	for count := 0; count < len(c.sortedSet); count++ {
+		member := c.ring[c.sortedSet[idx]]
+		name := (*member).String()
+		if _, ok := added[name]; ok && fits(name) {
+			return c.members[name]
+		}
+		idx++
+		if idx >= len(c.sortedSet) {
+			idx = 0
+		}
+	}
+	return nil
+}
+
+// redistributeAffected recomputes the owners of the partitions affected by the latest ring mutation
+// and leaves the stable ones untouched. A partition is affected if its owner left the ring, if
+// its owner exceeds the new expected load or if a position of one of the added members now
+// precedes its owner on the ring. It falls back to distributePartitions if there is no table yet.
+// The current table is left untouched if it returns an error.
+//
+// If MinimalDisruption is set, the partitions whose walk crosses an added member are handed to the added
+// members until they own their fair share, the rest of them stay with their owners instead of being
+// redistributed. The partitions whose owners exceed the new expected load go to the added members first.
+func (c *WeightedConsistent) redistributeAffected(ctx context.Context, added map[string]struct{}) error {
+	if len(c.partitions) == 0 || c.replicaSetSize() > 1 {
+		// The backups are only assigned from scratch, see WeightedConfig.ReplicaSetSize.
+		return c.distributePartitions(ctx)
+	}
+
+	started := time.Now()
+	avgLoad := c.averageLoad()
+	loads := make(map[string]float64)
+	partitions := make(map[int]*WeightedMember)
+	fits := func(name string, pw float64) bool {
+		return loads[name]+pw <= c.capacity(name, avgLoad)
+	}
+	// fair limits a member to its fair share of the partitions, without the headroom of Load.
+	fair := func(name string, pw float64) bool {
+		share := math.Ceil(c.partitionLoad / float64(c.totalWeight) * float64(c.weights[name]))
+		return loads[name]+pw <= math.Min(share, c.capacity(name, avgLoad))
+	}
+	// The ring positions are ignored with StableTieBreak, see WeightedConfig.StableTieBreak. The existing
+	// members keep only their fair shares instead, so that the added members get some partitions.
+	crossing := len(added) != 0 && !c.config.StableTieBreak
+	keeps := fits
+	if len(added) != 0 && c.config.StableTieBreak && !c.config.DisableBoundedLoad {
+		keeps = fair
+	}
+	minimal := c.config.MinimalDisruption && crossing
+
+	var affected, overloaded []int
+	for partID := 0; partID < int(c.partitionCount); partID++ {
+		if err := checkContext(ctx, partID); err != nil {
+			return err
+		}
+		owner, ok := c.partitions[partID]
+		if !ok {
+			affected = append(affected, partID)
+			continue
+		}
+		name := (*owner).String()
+		member, ok := c.members[name]
+		if !ok {
+			affected = append(affected, partID)
+			continue
+		}
+		pw := c.partitionWeight(partID)
+		if minimal {
+			idx := c.partitionIndex(partID)
+			if c.passesAdded(idx, name, added) {
+				target := c.addedSuccessor(idx, added, func(name string) bool { return fair(name, pw) })
+				if target != nil {
+					partitions[partID] = target
+					loads[(*target).String()] += pw
+					continue
+				}
+			}
+			if !fits(name, pw) {
+				overloaded = append(overloaded, partID)
+				continue
+			}
+			partitions[partID] = member
+			loads[name] += pw
+			continue
+		}
+		if !keeps(name, pw) {
+			affected = append(affected, partID)
+			continue
+		}
+		if crossing && c.passesAdded(c.partitionIndex(partID), name, added) {
+			affected = append(affected, partID)
+			continue
+		}
+		partitions[partID] = member
+		loads[name] += pw
+	}
+
+	// The owners of the overloaded partitions lost some capacity because of the added members,
+	// give the excess to the added members first.
+	for _, partID := range overloaded {
+		pw := c.partitionWeight(partID)
+		target := c.addedSuccessor(c.partitionIndex(partID), added, func(name string) bool { return fits(name, pw) })
+		if target != nil {
+			partitions[partID] = target
+			loads[(*target).String()] += pw
+			continue
+		}
+		affected = append(affected, partID)
+	}
+
+	for _, partID := range affected {
+		if err := c.distributeWithLoad(partID, c.partitionIndex(partID), partitions, loads); err != nil && !leaveUnassigned(c.config.OnDistributeFailure, partID, err) {
+			return err
+		}
+	}
+	c.setPartitions(partitions, loads, started)
+	return nil
+}
+
+func (c *WeightedConsistent) add(member WeightedMember) {
+	c.addWithWeight(member, member.Weight())
+}
+
+// checkReplicas returns ErrTooManyReplicas if a member with the given weight would exceed MaxReplicasPerMember.
+func (c *WeightedConsistent) checkReplicas(weight int) error {
+	if weight <= 0 {
+		weight = 1
+	}
+	if c.config.MaxReplicasPerMember > 0 && c.replicas(weight) > c.config.MaxReplicasPerMember {
+		return ErrTooManyReplicas
+	}
+	return nil
+}
+
+// replicas returns the number of ring positions of a member with the given weight.
+func (c *WeightedConsistent) replicas(weight int) int {
+	replicas := c.config.ReplicationFactor * weight
+	if replicas < c.config.MinReplicasPerMember {
+		return c.config.MinReplicasPerMember
+	}
+	return replicas
+}
+
+// checkExisting reports whether the member is already in the ring. It returns ErrMemberConflict if it's in
+// the ring with a different weight, the weight to restore of a draining member is compared.
+func (c *WeightedConsistent) checkExisting(member WeightedMember) (bool, error) {
+	if _, ok := c.members[member.String()]; !ok {
+		return false, nil
+	}
+	weight := member.Weight()
+	if weight <= 0 {
+		weight = 1
+	}
+	current, ok := c.draining[member.String()]
+	if !ok {
+		current = c.weights[member.String()]
+	}
+	if weight != current {
+		return true, ErrMemberConflict
+	}
+	return true, nil
+}
+
+// checkMember returns ErrEmptyMemberName if the name of the member is empty, ErrTooManyReplicas if the member
+// would exceed MaxReplicasPerMember and ErrHashKeyConflict if another member in the ring has the same hash key.
+func (c *WeightedConsistent) checkMember(member WeightedMember) error {
+	if member.String() == "" {
+		return ErrEmptyMemberName
+	}
+	if err := c.checkReplicas(member.Weight()); err != nil {
+		return err
+	}
+	key := memberHashKey(member)
+	for name, m := range c.members {
+		if name != member.String() && memberHashKey(*m) == key {
+			return ErrHashKeyConflict
+		}
+	}
+	return nil
+}
+
+// addWithWeight places the member on the ring with the given weight instead of member.Weight().
+func (c *WeightedConsistent) addWithWeight(member WeightedMember, weight int) {
+	if weight <= 0 {
+		weight = 1 // Ensure minimum weight of 1
+	}
+
+	// Store member and weight information. The member has to be stored first, its hash key is looked up
+	// while the replicas are placed.
+	c.members[member.String()] = &member
+	c.setWeight(member.String(), weight)
+
+	// Calculate replicas based on weight
+	c.addReplicas(&member, 0, c.replicas(weight))
+}
+
+// replicaHash returns the position of the idx-th replica of the member with the given hash key on the ring.
+func (c *WeightedConsistent) replicaHash(key string, idx int) uint64 {
+	return c.hasher.Sum64(seededKey(c.config.Seed, c.config.ReplicaKeyFunc(key, idx)))
+}
+
+// addReplicas places the replicas of the given member with indexes in [from, to) on the ring.
+func (c *WeightedConsistent) addReplicas(member *WeightedMember, from, to int) {
+	key := memberHashKey(*member)
+	hashes := make([]uint64, 0, to-from)
+	for i := from; i < to; i++ {
+		h := c.replicaHash(key, i)
+		c.ring[h] = member
+		hashes = append(hashes, h)
+	}
+	// sort the new hashes ascendingly and merge them into the already sorted set.
+	sort.Slice(hashes, func(i int, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+	c.mergeSlice(hashes)
+}
+
+// mergeSlice merges the given sorted hashes into sortedSet in linear time. The merge runs backwards
+// in place, so no allocation is needed beyond growing sortedSet.
+func (c *WeightedConsistent) mergeSlice(hashes []uint64) {
+	i := len(c.sortedSet) - 1
+	j := len(hashes) - 1
+	c.sortedSet = append(c.sortedSet, hashes...)
+	for k := len(c.sortedSet) - 1; j >= 0; k-- {
+		if i >= 0 && c.sortedSet[i] > hashes[j] {
+			c.sortedSet[k] = c.sortedSet[i]
+			i--
+		} else {
+			c.sortedSet[k] = hashes[j]
+			j--
+		}
+	}
+}
+
+// delReplicas removes the replicas of the given member with indexes in [from, to) from the ring.
+func (c *WeightedConsistent) delReplicas(name string, from, to int) {
+	key := memberHashKey(*c.members[name])
+	hashes := make([]uint64, 0, to-from)
+	for i := from; i < to; i++ {
+		h := c.replicaHash(key, i)
+		delete(c.ring, h)
+		hashes = append(hashes, h)
+	}
+	c.delSlice(hashes)
+}
+
+// setWeight stores the weight of a member. totalWeight is only ever adjusted here and in
+// delWeight, so it always equals the sum of the weights map.
+func (c *WeightedConsistent) setWeight(name string, weight int) {
+	c.totalWeight += weight - c.weights[name]
+	c.weights[name] = weight
+}
+
+// delWeight deletes the weight of a member and subtracts it from totalWeight. Unknown names are ignored,
+// so removing a member twice cannot subtract its weight twice.
+func (c *WeightedConsistent) delWeight(name string) {
+	weight, ok := c.weights[name]
+	if !ok {
+		return
+	}
+	delete(c.weights, name)
+	c.totalWeight -= weight
+	if c.totalWeight < 0 {
+		// It cannot happen as long as totalWeight is only adjusted by setWeight and delWeight,
+		// but a negative total weight breaks the expected loads. Recompute it from the weights.
+		c.totalWeight = 0
+		for _, weight := range c.weights {
+			c.totalWeight += weight
+		}
+	}
+}
+
+// Add adds a new weighted member to the consistent hash circle. It panics if the partitions cannot be
+// distributed or the member is rejected, use AddChecked to get an error instead. Adding a member which is
+// already in the ring with the same weight does nothing, but a different weight panics with ErrMemberConflict;
+// earlier versions silently ignored it. Use AddOrUpdate or UpdateWeight to change the weight of a member.
+func (c *WeightedConsistent) Add(member WeightedMember) {
+	if err := c.AddChecked(member); err != nil {
+		panic(err)
+	}
+}
+
+// AddChecked adds a new weighted member to the consistent hash circle. It returns ErrNotEnoughRoom
+// and leaves the ring unchanged if the partitions cannot be distributed, ErrTooManyReplicas if
+// the member exceeds MaxReplicasPerMember, ErrHashKeyConflict if another member has the same hash key,
+// ErrEmptyMemberName if the name of the member is empty or ErrMemberConflict if a member with the same name
+// but a different weight is in the ring. Adding a member which is already in the ring does nothing.
+func (c *WeightedConsistent) AddChecked(member WeightedMember) error {
+	return c.AddContext(context.Background(), member)
+}
+
+// AddContext is like AddChecked but gives up and returns ctx.Err() if ctx is cancelled while the partitions
+// are redistributed. The ring is left unchanged in that case.
+func (c *WeightedConsistent) AddContext(ctx context.Context, member WeightedMember) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if exists, err := c.checkExisting(member); exists {
+		// We already have this member. Quit immediately.
+		return err
+	}
+	if err := c.checkMember(member); err != nil {
+		return err
+	}
+	c.add(member)
+	if err := c.redistributeAffected(ctx, map[string]struct{}{member.String(): {}}); err != nil {
+		c.remove(member.String())
+		return err
+	}
+	atomic.AddUint64(&c.counters.adds, 1)
+	return nil
+}
+
+// AddMany adds the given weighted members to the consistent hash circle and redistributes the
+// partitions only once. Duplicates and members which are already in the ring with the same weight are
+// skipped. Like Add, it panics if the partitions cannot be distributed or a member is rejected, e.g. with
+// ErrMemberConflict if a name is already in the ring or repeated with a different weight, use AddManyChecked
+// to get an error instead.
+func (c *WeightedConsistent) AddMany(members []WeightedMember) {
+	if err := c.AddManyChecked(members); err != nil {
+		panic(err)
+	}
+}
+
+// AddManyChecked is like AddMany but returns the errors of AddChecked instead of panicking. The ring is left
+// unchanged if a member is rejected or the partitions cannot be distributed.
+func (c *WeightedConsistent) AddManyChecked(members []WeightedMember) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The new members may conflict with each other as well as with the members in the ring.
+	keys := make(map[string]string)
+	weights := make(map[string]int)
+	for _, member := range members {
+		exists, err := c.checkExisting(member)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		weight := member.Weight()
+		if weight <= 0 {
+			weight = 1
+		}
+		if w, ok := weights[member.String()]; ok && w != weight {
+			return ErrMemberConflict
+		}
+		weights[member.String()] = weight
+		if err := c.checkMember(member); err != nil {
+			return err
+		}
+		if name, ok := keys[memberHashKey(member)]; ok && name != member.String() {
+			return ErrHashKeyConflict
+		}
+		keys[memberHashKey(member)] = member.String()
+	}
+
+	added := make(map[string]struct{})
+	for _, member := range members {
+		if _, ok := c.members[member.String()]; ok {
+			continue
+		}
+		c.add(member)
+		added[member.String()] = struct{}{}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	if err := c.redistributeAffected(context.Background(), added); err != nil {
+		for name := range added {
+			c.remove(name)
+		}
+		return err
+	}
+	atomic.AddUint64(&c.counters.adds, uint64(len(added)))
+	return nil
+}
+
+// delSlice removes the given hashes from sortedSet in a single merge-style pass.
+func (c *WeightedConsistent) delSlice(vals []uint64) {
+	sort.Slice(vals, func(i int, j int) bool {
+		return vals[i] < vals[j]
+	})
+
+	res := c.sortedSet[:0]
+	var j int
+	for _, h := range c.sortedSet {
+		for j < len(vals) && vals[j] < h {
+			j++
+		}
+		if j < len(vals) && vals[j] == h {
+			j++
+			continue
+		}
+		res = append(res, h)
+	}
+	c.sortedSet = res
+}
+
+// Remove removes a weighted member from the consistent hash circle. Only the partitions of the removed member
+// move: each of them goes to the first member following the partition on the ring which has room for it,
+// and all the other partitions keep their owners, since removing a member never lowers the expected loads.
+// With ReplicaSetSize greater than 1 the partitions and their backups are redistributed from scratch instead.
+func (c *WeightedConsistent) Remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.members[name]
+	if !ok {
+		// There is no member with that name. Quit immediately.
+		return
+	}
+
+	c.remove(name)
+	atomic.AddUint64(&c.counters.removes, 1)
+	if len(c.members) == 0 {
+		// consistent hash ring is empty now. Reset the partition table.
+		c.setPartitions(make(map[int]*WeightedMember), make(map[string]float64), time.Now())
+		return
+	}
+	if err := c.redistributeAffected(context.Background(), nil); err != nil {
+		panic(err)
+	}
+}
+
+// RemoveMany removes the given members from the consistent hash circle and redistributes the
+// partitions only once. Unknown names are skipped.
+func (c *WeightedConsistent) RemoveMany(names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var removed int
+	for _, name := range names {
+		if _, ok := c.members[name]; !ok {
+			continue
+		}
+		c.remove(name)
+		removed++
+	}
+	if removed == 0 {
+		return
+	}
+	atomic.AddUint64(&c.counters.removes, uint64(removed))
+	if len(c.members) == 0 {
+		// consistent hash ring is empty now. Reset the partition table.
+		c.setPartitions(make(map[int]*WeightedMember), make(map[string]float64), time.Now())
+		return
+	}
+	if err := c.redistributeAffected(context.Background(), nil); err != nil {
+		panic(err)
+	}
+}
+
+func (c *WeightedConsistent) remove(name string) {
+	c.delReplicas(name, 0, c.replicas(c.weights[name]))
+	delete(c.members, name)
+	delete(c.draining, name)
+	c.delWeight(name)
+}
+
+// UpdateWeight changes the weight of a member in place. Only the difference of the replicas is added to
+// or removed from the ring and the partitions are redistributed once. A weight less than 1 is treated as 1.
+// It returns ErrMemberNotFound if there is no member with the given name, ErrTooManyReplicas if the new weight
+// exceeds MaxReplicasPerMember and ErrNotEnoughRoom if the partitions cannot be distributed, the weight is
+// left unchanged in the latter cases.
+func (c *WeightedConsistent) UpdateWeight(name string, newWeight int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.updateWeight(name, newWeight)
+}
+
+// AddOrUpdate adds the member if it's not in the ring, or changes its weight like UpdateWeight if it's
+// in the ring with a different weight. It does nothing if the member is in the ring with the same weight.
+// It returns the same errors as AddChecked and UpdateWeight.
+func (c *WeightedConsistent) AddOrUpdate(member WeightedMember) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := member.String()
+	if _, ok := c.members[name]; ok {
+		return c.updateWeight(name, member.Weight())
+	}
+	if err := c.checkMember(member); err != nil {
+		return err
+	}
+	c.add(member)
+	if err := c.redistributeAffected(context.Background(), map[string]struct{}{name: {}}); err != nil {
+		c.remove(name)
+		return err
+	}
+	atomic.AddUint64(&c.counters.adds, 1)
+	return nil
+}
+
+func (c *WeightedConsistent) updateWeight(name string, newWeight int) error {
+	if _, ok := c.members[name]; !ok {
+		return ErrMemberNotFound
+	}
+	if newWeight <= 0 {
+		newWeight = 1 // Ensure minimum weight of 1
+	}
+	if err := c.checkReplicas(newWeight); err != nil {
+		return err
+	}
+	if _, ok := c.draining[name]; ok {
+		// The new weight is applied when the member stops draining.
+		c.draining[name] = newWeight
+		return nil
+	}
+	return c.applyWeight(name, newWeight)
+}
+
+// applyWeight changes the weight of the member and redistributes the partitions. The weight is left
+// unchanged if the partitions cannot be distributed.
+func (c *WeightedConsistent) applyWeight(name string, newWeight int) error {
+	oldWeight := c.weights[name]
+	if newWeight == oldWeight {
+		return nil
+	}
+
+	c.reweight(name, newWeight)
+	var added map[string]struct{}
+	if newWeight > oldWeight {
+		added = map[string]struct{}{name: {}}
+	}
+	if err := c.redistributeAffected(context.Background(), added); err != nil {
+		c.reweight(name, oldWeight)
+		return err
+	}
+	return nil
+}
+
+// drainingWeight is the effective weight of a draining member, see SetDraining.
+const drainingWeight = 1
+
+// SetDraining starts or stops draining a member, e.g. to shift the load off it gradually before it's
+// removed. A draining member keeps its replicas of the lowest weight, 1, so it owns fewer partitions after
+// the redistribution, but it's still in the ring: GetClosestN and the other walks still reach it, e.g. for
+// the in-flight reads. Its weight is restored when it stops draining. UpdateWeight on a draining member
+// only changes the weight to restore. Draining a member twice does nothing.
+//
+// It returns ErrMemberNotFound if there is no member with the given name and ErrNotEnoughRoom if the
+// partitions cannot be distributed, the member is left as it was in that case.
+func (c *WeightedConsistent) SetDraining(name string, draining bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.members[name]; !ok {
+		return ErrMemberNotFound
+	}
+	weight, ok := c.draining[name]
+	if draining == ok {
+		return nil
+	}
+	if draining {
+		weight = c.weights[name]
+		if err := c.applyWeight(name, drainingWeight); err != nil {
+			return err
+		}
+		if c.draining == nil {
+			c.draining = make(map[string]int)
+		}
+		c.draining[name] = weight
+		return nil
+	}
+	if err := c.applyWeight(name, weight); err != nil {
+		return err
+	}
+	delete(c.draining, name)
+	return nil
+}
+
+// IsDraining reports whether the member is draining, see SetDraining.
+func (c *WeightedConsistent) IsDraining(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.draining[name]
+	return ok
+}
+
+// reweight sets the weight of the member and adds or removes only the difference of its replicas.
+// It doesn't redistribute the partitions.
+func (c *WeightedConsistent) reweight(name string, weight int) {
+	oldReplicas := c.replicas(c.weights[name])
+	newReplicas := c.replicas(weight)
+	c.setWeight(name, weight)
+	if newReplicas > oldReplicas {
+		c.addReplicas(c.members[name], oldReplicas, newReplicas)
+	} else {
+		c.delReplicas(name, newReplicas, oldReplicas)
+	}
+}
+
+// LoadDistribution exposes load distribution of weighted members.
+func (c *WeightedConsistent) LoadDistribution() map[string]float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Create a thread-safe copy
+	res := make(map[string]float64)
+	for member, load := range c.loads {
+		res[member] = load
+	}
+	return res
+}
+
+// WeightDistribution exposes weight distribution of members.
+func (c *WeightedConsistent) WeightDistribution() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Create a thread-safe copy
+	res := make(map[string]int)
+	for member, weight := range c.weights {
+		res[member] = weight
+	}
+	return res
+}
+
+// Weight returns the weight of the given member in the ring, like WeightDistribution, without copying the
+// weights of all the members. The weight of a draining member is its draining weight, see SetDraining.
+// The second result reports whether the member is in the ring.
+func (c *WeightedConsistent) Weight(name string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	weight, ok := c.weights[name]
+	return weight, ok
+}
+
+// OwnedPartitions returns the sorted IDs of the partitions owned by the given member.
+// It returns an empty slice for unknown members.
+func (c *WeightedConsistent) OwnedPartitions(name string) []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := []int{}
+	for partID, member := range c.partitions {
+		if (*member).String() == name {
+			res = append(res, partID)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+// PartitionTable returns a copy of the partition table as partition ID to owner name.
+// Partitions without an owner are omitted.
+func (c *WeightedConsistent) PartitionTable() map[int]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := make(map[int]string, len(c.partitions))
+	for partID, member := range c.partitions {
+		if member == nil || *member == nil {
+			continue
+		}
+		res[partID] = (*member).String()
+	}
+	return res
+}
+
+// PartitionRanges returns the [start, end) interval every partition covers. FindPartitionID maps a key to
+// hash(key) % PartitionCount, so the intervals are over that residue space rather than the raw 64-bit hash
+// space: partition i covers [i, i+1) and a raw hash h belongs to the partition whose interval contains
+// h % PartitionCount. Ordered data must be keyed by that residue to turn the intervals into range scans.
+func (c *WeightedConsistent) PartitionRanges() map[int][2]uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := make(map[int][2]uint64, c.partitionCount)
+	for partID := uint64(0); partID < c.partitionCount; partID++ {
+		res[int(partID)] = [2]uint64{partID, partID + 1}
+	}
+	return res
+}
+
+// OwnerRanges returns the intervals of PartitionRanges grouped by owner name. Adjacent partitions of the same
+// owner are merged into a single interval and the intervals of every owner are sorted.
+func (c *WeightedConsistent) OwnerRanges() map[string][][2]uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := make(map[string][][2]uint64)
+	prev := ""
+	for partID := uint64(0); partID < c.partitionCount; partID++ {
+		owner := c.getPartitionOwner(int(partID))
+		if owner == nil {
+			prev = ""
+			continue
+		}
+		name := owner.String()
+		ranges := res[name]
+		if name == prev {
+			ranges[len(ranges)-1][1] = partID + 1
+			continue
+		}
+		res[name] = append(ranges, [2]uint64{partID, partID + 1})
+		prev = name
+	}
+	return res
+}
+
+// EachPartition calls fn for every owned partition in ascending partition order and stops early if fn returns
+// false. The read lock is held during the iteration, so fn must not modify the ring.
+func (c *WeightedConsistent) EachPartition(fn func(partID int, owner WeightedMember) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for partID := 0; partID < int(c.partitionCount); partID++ {
+		owner := c.getPartitionOwner(partID)
+		if owner == nil {
+			continue
+		}
+		if !fn(partID, owner) {
+			return
+		}
+	}
+}
+
+// SetPartitionCount changes the number of partitions and distributes them from scratch. It returns
+// ErrInvalidPartitionCount if n is not positive and ErrNotEnoughRoom if the partitions cannot be distributed,
+// the partition count is left unchanged in both cases.
+//
+// FindPartitionID maps the keys with the new modulus, so nearly every key moves to a different partition and
+// the owners of most partitions change as well. It's a migration of the whole data set: take a Clone before
+// the call and compare it with MigrationPlan, or register OnPartitionMoved, to find out what moved. Growing
+// the partition count by an integer factor, see GrowPartitionCount, keeps every key within the partitions
+// which descend from its old partition.
+func (c *WeightedConsistent) SetPartitionCount(n int) error {
+	if n <= 0 {
+		return ErrInvalidPartitionCount
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.setPartitionCount(n)
+}
+
+// GrowPartitionCount multiplies the partition count by factor. A key in partition p moves to one of the
+// partitions p, p+n, ..., p+(factor-1)*n where n is the old count, since the keys are mapped with a modulo,
+// so the data can be migrated partition by partition, see ParentPartition. The owners of the partitions
+// are still distributed from scratch. It returns ErrInvalidPartitionCount if factor is not positive and
+// ErrNotEnoughRoom if the partitions cannot be distributed.
+func (c *WeightedConsistent) GrowPartitionCount(factor int) error {
+	if factor <= 0 {
+		return ErrInvalidPartitionCount
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.setPartitionCount(int(c.partitionCount) * factor)
+}
+
+// ParentPartition returns the partition which held the keys of the given partition before the last change
+// of the partition count: partID modulo the old count. The keys only have a parent if the new count is a
+// multiple of the old one, otherwise the modulo scatters them over all the partitions and it returns -1.
+// It returns partID if the partition count never changed and -1 if partID is out of range.
+func (c *WeightedConsistent) ParentPartition(partID int) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if partID < 0 || uint64(partID) >= c.partitionCount {
+		return -1
+	}
+	if c.previousPartitionCount == 0 {
+		return partID
+	}
+	if c.partitionCount%c.previousPartitionCount != 0 {
+		return -1
+	}
+	return int(uint64(partID) % c.previousPartitionCount)
+}
+
+func (c *WeightedConsistent) setPartitionCount(n int) error {
+	if uint64(n) == c.partitionCount {
+		return nil
+	}
+	old := c.partitionCount
+	c.partitionCount = uint64(n)
+	c.initPartitions()
+	if len(c.members) == 0 {
+		c.config.PartitionCount = n
+		c.previousPartitionCount = old
+		c.version++
+		c.publish()
+		return nil
+	}
+	if err := c.distributePartitions(context.Background()); err != nil {
+		c.partitionCount = old
+		c.initPartitions()
+		return err
+	}
+	c.config.PartitionCount = n
+	c.previousPartitionCount = old
+	return nil
+}
+
+// PartitionCount returns the number of partitions.
+func (c *WeightedConsistent) PartitionCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return int(c.partitionCount)
+}
+
+// Hasher returns the Hasher of the ring, DefaultHasher if the config didn't set one. It can be used to
+// hash the keys the same way outside the ring, e.g. to precompute partition IDs. It doesn't take the lock,
+// the hasher never changes after construction.
+func (c *WeightedConsistent) Hasher() Hasher {
+	return c.hasher
+}
+
+// Config returns a copy of the config of the ring with the defaults filled in. PartitionCount reflects the
+// changes made by SetPartitionCount and GrowPartitionCount.
+func (c *WeightedConsistent) Config() WeightedConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.config
+}
+
+// FindPartitionID returns partition id for given key. It doesn't take the lock: the hasher never changes
+// after construction and the partition count is read from the published partition table, so it's safe to
+// call concurrently with the mutations of the ring.
+func (c *WeightedConsistent) FindPartitionID(key []byte) int {
+	hkey := c.hasher.Sum64(key)
+	return int(hkey % c.loadTable().partitionCount)
+}
+
+// findPartitionID is like FindPartitionID but uses the partition count of the ring, so that the partition ID
+// is consistent with the other fields read under the same lock. It's not thread-safe.
+func (c *WeightedConsistent) findPartitionID(key []byte) int {
+	return int(c.hasher.Sum64(key) % c.partitionCount)
+}
+
+// GetPartitionOwner returns the owner of the given partition. It doesn't take the lock, the owner is read
+// from the partition table published by the latest redistribution.
+func (c *WeightedConsistent) GetPartitionOwner(partID int) WeightedMember {
+	owners := c.loadTable().owners
+	if partID < 0 || partID >= len(owners) {
+		return nil
+	}
+	return owners[partID]
+}
+
+// getPartitionOwner returns the owner of the given partition. It's not thread-safe.
+func (c *WeightedConsistent) getPartitionOwner(partID int) WeightedMember {
+	member, ok := c.partitions[partID]
+	if !ok {
+		return nil
+	}
+	// Create a thread-safe copy of member and return it.
+	return *member
+}
+
+// LocateKey finds a home for given key considering member weights. It doesn't take the lock, the partition ID
+// and the owner are computed from the same published partition table.
+func (c *WeightedConsistent) LocateKey(key []byte) WeightedMember {
+	t := c.loadTable()
+	partID := c.hasher.Sum64(key) % t.partitionCount
+	return t.owners[partID]
+}
+
+// LocateKeyString is like LocateKey but takes a string key. The key is copied to a byte slice internally,
+// it's a convenience for the callers and doesn't save the conversion.
+func (c *WeightedConsistent) LocateKeyString(key string) WeightedMember {
+	return c.LocateKey([]byte(key))
+}
+
+// LocateKeyWithSalt is like LocateKey but hashes salt followed by key, e.g. to map the same key of different
+// tenants independently. The partition ID is still the hash modulo the partition count.
+func (c *WeightedConsistent) LocateKeyWithSalt(salt, key []byte) WeightedMember {
+	salted := make([]byte, 0, len(salt)+len(key))
+	salted = append(append(salted, salt...), key...)
+	return c.LocateKey(salted)
+}
+
+// FindPartitionIDString is like FindPartitionID but takes a string key, see LocateKeyString.
+func (c *WeightedConsistent) FindPartitionIDString(key string) int {
+	return c.FindPartitionID([]byte(key))
+}
+
+// OwnerChanged reports whether the key is now owned by a member other than previousOwner. An empty
+// previousOwner stands for no owner, which is the case when the ring is empty.
+func (c *WeightedConsistent) OwnerChanged(key []byte, previousOwner string) bool {
+	owner := c.LocateKey(key)
+	if owner == nil {
+		return previousOwner != ""
+	}
+	return owner.String() != previousOwner
+}
+
+// ownerIndex returns the position of the given partition's owner on the ring. The ring is walked from
+// the partition's hash the same way distributeWithLoad does, so this is the replica that took the partition.
+// It returns -1 if the owner has no position on the ring. It's not thread-safe.
+func (c *WeightedConsistent) ownerIndex(partID int, owner string) int {
+	idx := c.partitionIndex(partID)
+	for count := 0; count < len(c.sortedSet); count++ {
+		if (*c.ring[c.sortedSet[idx]]).String() == owner {
+			return idx
 		}
 		idx++
+		if idx >= len(c.sortedSet) {
+			idx = 0
+		}
 	}
+	return -1
+}
+
+// LocateKeys finds homes for the given keys under a single read lock. The result preserves the order of the keys.
+// Keys of the same partition share a single owner lookup.
+func (c *WeightedConsistent) LocateKeys(keys [][]byte) []WeightedMember {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := make([]WeightedMember, len(keys))
+	owners := make([]WeightedMember, c.partitionCount)
+	resolved := make([]bool, c.partitionCount)
+	for i, key := range keys {
+		partID := int(c.hasher.Sum64(key) % c.partitionCount)
+		if !resolved[partID] {
+			owners[partID] = c.getPartitionOwner(partID)
+			resolved[partID] = true
+		}
+		res[i] = owners[partID]
+	}
+	return res
+}
+
+// sampleKeysAttempts bounds the number of keys SampleKeys tries: n * PartitionCount * sampleKeysAttempts.
+// A member owning a single partition is expected to own one in every PartitionCount keys.
+const sampleKeysAttempts = 4
+
+// SampleKeys returns up to n keys which LocateKey maps to the given member, e.g. to warm up its cache. The
+// keys are the decimal numbers "0", "1", ... tried in order, so the result is deterministic for a given
+// partition table. It's probabilistic: a limited number of keys is tried, so it may return fewer than n
+// keys if the member owns a small fraction of the partitions. It returns nil for unknown members, members
+// without partitions and if n is not positive.
+func (c *WeightedConsistent) SampleKeys(name string, n int) [][]byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if n <= 0 {
+		return nil
+	}
+	owned := make(map[uint64]struct{})
+	for partID, member := range c.partitions {
+		if (*member).String() == name {
+			owned[uint64(partID)] = struct{}{}
+		}
+	}
+	if len(owned) == 0 {
+		return nil
+	}
+
+	var res [][]byte
+	attempts := n * int(c.partitionCount) * sampleKeysAttempts
+	for i := 0; i < attempts && len(res) < n; i++ {
+		key := strconv.AppendInt(nil, int64(i), 10)
+		if _, ok := owned[c.hasher.Sum64(key)%c.partitionCount]; ok {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// walkMembers calls fn for every member in ring order, starting from the replica of the partition's owner.
+// Replicas of already visited members are skipped. The walk stops when fn returns false. It's not thread-safe.
+func (c *WeightedConsistent) walkMembers(partID int, fn func(member WeightedMember) bool) {
+	c.walkPartitionMembers(partID, make(map[string]struct{}), fn)
+}
+
+// walkPartitionMembers is like walkMembers but records the visited members in the given empty map, so the
+// callers walking many partitions can reuse it. It's not thread-safe.
+func (c *WeightedConsistent) walkPartitionMembers(partID int, visited map[string]struct{}, fn func(member WeightedMember) bool) {
+	owner := c.getPartitionOwner(partID)
+	if owner == nil {
+		return
+	}
+	idx := c.ownerIndex(partID, owner.String())
+	if idx < 0 {
+		return
+	}
+	c.walkMembersFrom(idx, visited, func(_ int, member WeightedMember) bool {
+		return fn(member)
+	})
+}
 
-	// Find the closest(replica owners) members.
-	for len(res) < count {
+// walkMembersFrom is like walkPartitionMembers but starts from the given index of sortedSet, and passes fn
+// the index where every member is reached. It's not thread-safe.
+func (c *WeightedConsistent) walkMembersFrom(idx int, visited map[string]struct{}, fn func(idx int, member WeightedMember) bool) {
+	for i := 0; i < len(c.sortedSet); i++ {
+		member := *c.ring[c.sortedSet[idx]]
+		if _, ok := visited[member.String()]; !ok {
+			visited[member.String()] = struct{}{}
+			if !fn(idx, member) {
+				return
+			}
+		}
 		idx++
-		if idx >= len(keys) {
+		if idx >= len(c.sortedSet) {
 			idx = 0
 		}
-		key := keys[idx]
-		res = append(res, *kmems[key])
+	}
+}
+
+// closestN returns up to count members of the partition in the order of GetClosestN, skipping the members
+// for which skip returns true, if it's not nil, and the members whose anti-affinity group is already in the
+// result, see WeightedConfig.AntiAffinityFunc. visited must be empty. It's not thread-safe.
+func (c *WeightedConsistent) closestN(partID, count int, visited map[string]struct{}, skip func(name string) bool) []WeightedMember {
+	res := make([]WeightedMember, 0, count)
+	var groups map[string]struct{}
+	if c.config.AntiAffinityFunc != nil {
+		groups = make(map[string]struct{}, count)
+	}
+	c.walkPartitionMembers(partID, visited, func(member WeightedMember) bool {
+		if skip != nil && skip(member.String()) {
+			return true
+		}
+		if groups != nil {
+			group := c.config.AntiAffinityFunc(member.String())
+			if _, ok := groups[group]; ok {
+				return true
+			}
+			groups[group] = struct{}{}
+		}
+		res = append(res, member)
+		return len(res) < count
+	})
+	return res
+}
+
+// getClosestN returns the closest N members of the partition, see GetClosestN. It's not thread-safe.
+func (c *WeightedConsistent) getClosestN(partID, count int) ([]WeightedMember, error) {
+	var res []WeightedMember
+	if count <= 0 {
+		return res, ErrInvalidCount
+	}
+	if len(c.members) == 0 {
+		return res, ErrEmptyRing
+	}
+	if count > len(c.members) {
+		return res, ErrInsufficientMemberCount
+	}
+
+	// Walk the ring starting from the owner's replica and skip the replicas
+	// of the members that are already selected. The walk stops at the last member
+	// found, so requesting all the members doesn't walk the rest of the ring.
+	res = c.closestN(partID, count, make(map[string]struct{}, count), nil)
+	if len(res) < count {
+		return res, ErrInsufficientMemberCount
 	}
 	return res, nil
 }
 
 // GetClosestN returns the closest N weighted member to a key in the hash ring.
-// This may be useful to find members for replication.
+// The owner of the key is the first element. This may be useful to find members for replication.
+// The members are in ring-successor order: the ring is walked from the owner's replica and every member
+// is taken at its first replica, so GetClosestN(key, k) is a prefix of GetClosestN(key, n) for k < n, and
+// requesting all the members returns every member once in that order.
+// It returns ErrInvalidCount if count is not positive and ErrEmptyRing if the ring has no members.
 func (c *WeightedConsistent) GetClosestN(key []byte, count int) ([]WeightedMember, error) {
-	partID := c.FindPartitionID(key)
-	return c.getClosestN(partID, count)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.getClosestN(c.findPartitionID(key), count)
+}
+
+// GetClosestNExcludingOwner returns the N distinct members which follow the owner of the key, in the same
+// order as GetClosestN but without the owner: it equals GetClosestN(key, count+1)[1:]. It returns
+// ErrInsufficientMemberCount if there are fewer than N members besides the owner and ErrInvalidCount if
+// count is not positive.
+func (c *WeightedConsistent) GetClosestNExcludingOwner(key []byte, count int) ([]WeightedMember, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	partID := c.findPartitionID(key)
+
+	if count <= 0 {
+		return nil, ErrInvalidCount
+	}
+	if len(c.members) == 0 {
+		return nil, ErrEmptyRing
+	}
+	if count+1 > len(c.members) {
+		return nil, ErrInsufficientMemberCount
+	}
+
+	res := c.closestN(partID, count+1, make(map[string]struct{}, count+1), nil)
+	if len(res) < count+1 {
+		return nil, ErrInsufficientMemberCount
+	}
+	return res[1:], nil
+}
+
+// GetClosestNFiltered is like GetClosestN but skips the members for which skip returns true, e.g. the
+// members in maintenance, without removing them from the ring. The walk continues past the skipped members,
+// so the result is GetClosestN without the skipped members. It returns ErrInsufficientMemberCount if there
+// are fewer than N members which aren't skipped. skip is called with the read lock held.
+func (c *WeightedConsistent) GetClosestNFiltered(key []byte, count int, skip func(name string) bool) ([]WeightedMember, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	partID := c.findPartitionID(key)
+
+	if count <= 0 {
+		return nil, ErrInvalidCount
+	}
+	if len(c.members) == 0 {
+		return nil, ErrEmptyRing
+	}
+	if count > len(c.members) {
+		return nil, ErrInsufficientMemberCount
+	}
+
+	res := c.closestN(partID, count, make(map[string]struct{}), skip)
+	if len(res) < count {
+		return nil, ErrInsufficientMemberCount
+	}
+	return res, nil
+}
+
+// LocateKeyHealthy is like LocateKey but falls through to the next members on the ring if the owner isn't
+// healthy. It returns the first member for which isHealthy returns true, in the order of GetClosestN, or nil
+// if none of them is healthy or the ring is empty. isHealthy is called with the read lock held.
+func (c *WeightedConsistent) LocateKeyHealthy(key []byte, isHealthy func(name string) bool) WeightedMember {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	partID := c.findPartitionID(key)
+
+	var res WeightedMember
+	c.walkMembers(partID, func(member WeightedMember) bool {
+		if isHealthy(member.String()) {
+			res = member
+			return false
+		}
+		return true
+	})
+	return res
+}
+
+// LocateKeyBalanced is like LocateKey but skips the members which are overloaded according to the live
+// loads given by the caller, e.g. their open connections, like the bounded loads skip the members which
+// have no room for a partition. A member may take up to Load times its weight share of the total live load
+// plus the new key; the ring is walked from the owner in the order of GetClosestN and the first member
+// under that limit is returned. It trades consistency for balance: the same key may be located on another
+// member while its owner is overloaded. The owner is returned if all the members are overloaded and nil if
+// the ring is empty.
+func (c *WeightedConsistent) LocateKeyBalanced(key []byte, currentLoads map[string]int) WeightedMember {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	partID := c.findPartitionID(key)
+
+	if c.totalWeight == 0 {
+		return nil
+	}
+	total := 1
+	for name := range c.members {
+		total += currentLoads[name]
+	}
+	perWeight := float64(total) / float64(c.totalWeight) * c.config.Load
+
+	var res WeightedMember
+	c.walkMembers(partID, func(member WeightedMember) bool {
+		name := member.String()
+		if float64(currentLoads[name]+1) <= math.Ceil(perWeight*float64(c.weights[name])) {
+			res = member
+			return false
+		}
+		return true
+	})
+	if res == nil {
+		return c.getPartitionOwner(partID)
+	}
+	return res
+}
+
+// SecondaryOwner returns the first member after the owner of the key in ring order, e.g. to send it a hinted
+// handoff while the owner is unreachable. It equals GetClosestN(key, 2)[1] without allocating the result.
+// With AntiAffinityFunc, the members in the owner's group are skipped as well. It returns nil if there is
+// no such member, e.g. the ring has fewer than two members.
+func (c *WeightedConsistent) SecondaryOwner(key []byte) WeightedMember {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	partID := c.findPartitionID(key)
+
+	if len(c.members) < 2 {
+		return nil
+	}
+	owner := c.getPartitionOwner(partID)
+	if owner == nil {
+		return nil
+	}
+	idx := c.ownerIndex(partID, owner.String())
+	if idx < 0 {
+		return nil
+	}
+	var group string
+	if c.config.AntiAffinityFunc != nil {
+		group = c.config.AntiAffinityFunc(owner.String())
+	}
+	// The first replica of another member follows the owner's replica, the replicas in between are the owner's.
+	for i := 0; i < len(c.sortedSet); i++ {
+		member := *c.ring[c.sortedSet[idx]]
+		if member.String() != owner.String() &&
+			(c.config.AntiAffinityFunc == nil || c.config.AntiAffinityFunc(member.String()) != group) {
+			return member
+		}
+		idx++
+		if idx >= len(c.sortedSet) {
+			idx = 0
+		}
+	}
+	return nil
+}
+
+// ReplicaSets returns the closest N members of every partition, like GetClosestNForPartition, computed under
+// a single read lock. It's meant to resolve the replica sets of all the partitions at once, e.g. at startup.
+// It returns ErrInvalidCount if count is not positive and ErrInsufficientMemberCount if there are fewer than
+// N members.
+func (c *WeightedConsistent) ReplicaSets(count int) (map[int][]WeightedMember, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if count <= 0 {
+		return nil, ErrInvalidCount
+	}
+	if len(c.members) == 0 {
+		return nil, ErrEmptyRing
+	}
+	if count > len(c.members) {
+		return nil, ErrInsufficientMemberCount
+	}
+
+	sets := make(map[int][]WeightedMember, c.partitionCount)
+	visited := make(map[string]struct{}, count)
+	for partID := 0; partID < int(c.partitionCount); partID++ {
+		for name := range visited {
+			delete(visited, name)
+		}
+		set := c.closestN(partID, count, visited, nil)
+		if len(set) < count {
+			return nil, ErrInsufficientMemberCount
+		}
+		sets[partID] = set
+	}
+	return sets, nil
+}
+
+// GetReplicaSet returns the members the partition is stored on, the owner first, followed by its backups
+// if ReplicaSetSize is greater than 1. It returns nil if the partition has no owner.
+func (c *WeightedConsistent) GetReplicaSet(partID int) []WeightedMember {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	owner, ok := c.partitions[partID]
+	if !ok {
+		return nil
+	}
+	set := make([]WeightedMember, 0, 1+len(c.backups[partID]))
+	set = append(set, *owner)
+	for _, member := range c.backups[partID] {
+		set = append(set, *member)
+	}
+	return set
+}
+
+// ReplicaInfo is a member returned by GetClosestNWithDistance.
+type ReplicaInfo struct {
+	Member WeightedMember
+	// Distance is the clockwise gap on the ring from the key's partition to the nearest replica of the member.
+	Distance uint64
+}
+
+// GetClosestNWithDistance returns the N members whose replicas are the nearest to the key's partition on the
+// ring, with their distances, nearest first. The distances are non-decreasing. The members are the ones
+// GetClosestN returns, in the same order, unless the bounded loads moved the partition past its nearest
+// members: then those come first here, since they're nearer. It returns ErrInvalidCount if count is not
+// positive and ErrInsufficientMemberCount if there are fewer than N members.
+func (c *WeightedConsistent) GetClosestNWithDistance(key []byte, count int) ([]ReplicaInfo, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	partID := c.findPartitionID(key)
+
+	if count <= 0 {
+		return nil, ErrInvalidCount
+	}
+	if len(c.members) == 0 {
+		return nil, ErrEmptyRing
+	}
+	if count > len(c.members) {
+		return nil, ErrInsufficientMemberCount
+	}
+
+	origin := c.partitionHashes[partID]
+	res := make([]ReplicaInfo, 0, count)
+	c.walkMembersFrom(c.partitionIndex(partID), make(map[string]struct{}), func(idx int, member WeightedMember) bool {
+		// The walk doesn't complete a lap, so the wrapping subtraction is the clockwise gap.
+		res = append(res, ReplicaInfo{Member: member, Distance: c.sortedSet[idx] - origin})
+		return len(res) < count
+	})
+	if len(res) < count {
+		return nil, ErrInsufficientMemberCount
+	}
+	return res, nil
 }
 
 // GetClosestNForPartition returns the closest N weighted member for given partition.
 // This may be useful to find members for replication.
 func (c *WeightedConsistent) GetClosestNForPartition(partID, count int) ([]WeightedMember, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	return c.getClosestN(partID, count)
 }
 
+// GetClosestNDistinctZones returns the closest N weighted members to a key whose zones are all different.
+// Zones are determined by WeightedConfig.ZoneFunc, every member is in its own zone if it's not set.
+// It returns ErrInsufficientMemberCount if there are fewer than N distinct zones.
+func (c *WeightedConsistent) GetClosestNDistinctZones(key []byte, count int) ([]WeightedMember, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	partID := c.findPartitionID(key)
+
+	if count <= 0 {
+		return nil, ErrInvalidCount
+	}
+	if len(c.members) == 0 {
+		return nil, ErrEmptyRing
+	}
+	zoneFunc := c.config.ZoneFunc
+	if zoneFunc == nil {
+		zoneFunc = func(name string) string { return name }
+	}
+
+	var res []WeightedMember
+	zones := make(map[string]struct{})
+	c.walkMembers(partID, func(member WeightedMember) bool {
+		zone := zoneFunc(member.String())
+		if _, ok := zones[zone]; ok {
+			return true
+		}
+		zones[zone] = struct{}{}
+		res = append(res, member)
+		return len(res) < count
+	})
+	if len(res) < count {
+		return nil, ErrInsufficientMemberCount
+	}
+	return res, nil
+}
+
 // GetTotalWeight returns the total weight of all members.
 func (c *WeightedConsistent) GetTotalWeight() int {
 	c.mu.RLock()
@@ -1,9 +1,18 @@
 package consistent
 
 import (
-	"hash/fnv"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 )
 
 // Test hasher for weighted consistent tests
@@ -174,6 +183,44 @@ func TestWeightedConsistent_LocateKey(t *testing.T) {
 	}
 }
 
+func TestWeightedConsistent_LocateKeys(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+		testWeightedMember{name: "server3", weight: 3},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	keys := make([][]byte, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("key-%d", i)))
+	}
+
+	owners := c.LocateKeys(keys)
+	if len(owners) != len(keys) {
+		t.Fatalf("Expected %d owners, got %d", len(keys), len(owners))
+	}
+	for i, key := range keys {
+		if owners[i].String() != c.LocateKey(key).String() {
+			t.Fatalf("Expected %s for %s, got %s", c.LocateKey(key), key, owners[i])
+		}
+	}
+
+	for _, owner := range NewWeighted(nil, cfg).LocateKeys(keys[:10]) {
+		if owner != nil {
+			t.Fatalf("Expected nil on an empty ring, got %s", owner)
+		}
+	}
+}
+
 func TestWeightedConsistent_GetClosestN(t *testing.T) {
 	members := []WeightedMember{
 		testWeightedMember{name: "server1", weight: 2},
@@ -206,6 +253,123 @@ func TestWeightedConsistent_GetClosestN(t *testing.T) {
 	if err != ErrInsufficientMemberCount {
 		t.Fatalf("Expected ErrInsufficientMemberCount, got %v", err)
 	}
+
+	for _, count := range []int{0, -1} {
+		if _, err := c.GetClosestN(key, count); err != ErrInvalidCount {
+			t.Fatalf("Expected ErrInvalidCount for %d, got %v", count, err)
+		}
+		if _, err := c.GetClosestNForPartition(0, count); err != ErrInvalidCount {
+			t.Fatalf("Expected ErrInvalidCount for %d, got %v", count, err)
+		}
+		if _, err := c.GetClosestNExcludingOwner(key, count); err != ErrInvalidCount {
+			t.Fatalf("Expected ErrInvalidCount for %d, got %v", count, err)
+		}
+		if _, err := c.GetClosestNDistinctZones(key, count); err != ErrInvalidCount {
+			t.Fatalf("Expected ErrInvalidCount for %d, got %v", count, err)
+		}
+	}
+}
+
+func TestWeightedConsistent_GetClosestNRingOrder(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 3) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		closest, err := c.GetClosestN(key, 4)
+		if err != nil {
+			t.Fatalf("GetClosestN returned error: %v", err)
+		}
+		if closest[0].String() != c.LocateKey(key).String() {
+			t.Fatalf("Expected the owner %s as the first member, got %s", c.LocateKey(key), closest[0])
+		}
+
+		seen := make(map[string]struct{})
+		for _, member := range closest {
+			if _, ok := seen[member.String()]; ok {
+				t.Fatalf("%s is returned more than once", member)
+			}
+			seen[member.String()] = struct{}{}
+		}
+
+		again, err := c.GetClosestN(key, 4)
+		if err != nil {
+			t.Fatalf("GetClosestN returned error: %v", err)
+		}
+		for j := range closest {
+			if closest[j].String() != again[j].String() {
+				t.Fatalf("GetClosestN returned different members for the same key")
+			}
+		}
+	}
+}
+
+func TestWeightedConsistent_GetClosestNDistinctZones(t *testing.T) {
+	zones := map[string]string{
+		"node0.olric": "zone-a",
+		"node1.olric": "zone-a",
+		"node2.olric": "zone-a",
+		"node3.olric": "zone-a",
+		"node4.olric": "zone-b",
+		"node5.olric": "zone-b",
+		"node6.olric": "zone-c",
+	}
+
+	members := make([]WeightedMember, 0, len(zones))
+	for name := range zones {
+		members = append(members, testWeightedMember{name: name, weight: 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		ZoneFunc: func(name string) string {
+			return zones[name]
+		},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		closest, err := c.GetClosestNDistinctZones(key, 3)
+		if err != nil {
+			t.Fatalf("GetClosestNDistinctZones returned error: %v", err)
+		}
+		if len(closest) != 3 {
+			t.Fatalf("Expected 3 members, got %d", len(closest))
+		}
+		if closest[0].String() != c.LocateKey(key).String() {
+			t.Fatalf("Expected the owner as the first member, got %s", closest[0])
+		}
+		seen := make(map[string]struct{})
+		for _, member := range closest {
+			if _, ok := seen[zones[member.String()]]; ok {
+				t.Fatalf("Zone %s is represented more than once for %s", zones[member.String()], key)
+			}
+			seen[zones[member.String()]] = struct{}{}
+		}
+	}
+
+	if _, err := c.GetClosestNDistinctZones([]byte("test-key"), 4); err != ErrInsufficientMemberCount {
+		t.Fatalf("Expected ErrInsufficientMemberCount, got %v", err)
+	}
 }
 
 func TestWeightedConsistent_LoadDistribution(t *testing.T) {
@@ -217,7 +381,7 @@ func TestWeightedConsistent_LoadDistribution(t *testing.T) {
 	cfg := WeightedConfig{
 		PartitionCount:    100,
 		ReplicationFactor: 10,
-		Load:              1.0,
+		Load:              1.01,
 		Hasher:            testWeightedHasher{},
 	}
 
@@ -279,9 +443,11 @@ func TestWeightedConsistent_WeightDistribution(t *testing.T) {
 	}
 }
 
-func TestWeightedConsistent_ZeroWeight(t *testing.T) {
+func TestWeightedConsistent_OwnedPartitions(t *testing.T) {
 	members := []WeightedMember{
-		testWeightedMember{name: "server1", weight: 0}, // Zero weight should be treated as 1
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+		testWeightedMember{name: "server3", weight: 3},
 	}
 
 	cfg := WeightedConfig{
@@ -293,42 +459,3987 @@ func TestWeightedConsistent_ZeroWeight(t *testing.T) {
 
 	c := NewWeighted(members, cfg)
 
-	weights := c.WeightDistribution()
-	if weights["server1"] != 1 {
-		t.Fatalf("Expected zero weight to be treated as 1, got %d", weights["server1"])
+	if c.PartitionCount() != 71 {
+		t.Fatalf("Expected partition count 71, got %d", c.PartitionCount())
+	}
+
+	var total int
+	for _, member := range members {
+		owned := c.OwnedPartitions(member.String())
+		for i, partID := range owned {
+			if i > 0 && owned[i-1] >= partID {
+				t.Fatalf("Partition IDs of %s are not sorted: %v", member, owned)
+			}
+			if owner := c.GetPartitionOwner(partID); owner.String() != member.String() {
+				t.Fatalf("Expected %s to own partition %d, got %s", member, partID, owner)
+			}
+		}
+		if float64(len(owned)) != c.LoadDistribution()[member.String()] {
+			t.Fatalf("Expected %s to own %.0f partitions, got %d", member, c.LoadDistribution()[member.String()], len(owned))
+		}
+		total += len(owned)
+	}
+	if total != c.PartitionCount() {
+		t.Fatalf("Expected %d owned partitions in total, got %d", c.PartitionCount(), total)
+	}
+
+	owned := c.OwnedPartitions("nonexistent")
+	if owned == nil || len(owned) != 0 {
+		t.Fatalf("Expected an empty slice for an unknown member, got %v", owned)
 	}
 }
 
-func abs(x float64) float64 {
-	if x < 0 {
-		return -x
+func TestWeightedConsistent_ReplicaKeyCollision(t *testing.T) {
+	// "server1" with index 10 and "server11" with index 0 used to be hashed as "server110".
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server11", weight: 1},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	if len(c.ring) != len(c.sortedSet) || len(c.sortedSet) != 30 {
+		t.Fatalf("Replicas collided: %d distinct positions for %d replicas", len(c.ring), len(c.sortedSet))
+	}
+
+	c.Remove("server11")
+	for _, h := range c.sortedSet {
+		member, ok := c.ring[h]
+		if !ok || (*member).String() != "server1" {
+			t.Fatalf("Position %d doesn't belong to server1 after removing server11", h)
+		}
 	}
-	return x
 }
 
-// Benchmark weighted consistent hash performance
-func BenchmarkWeightedConsistent_LocateKey(b *testing.B) {
-	members := make([]WeightedMember, 0, 100)
-	for i := 0; i < 100; i++ {
-		weight := (i % 5) + 1 // Weights from 1 to 5
+func TestWeightedConsistent_MembersCountAndHas(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(nil, cfg)
+	if c.MembersCount() != 0 {
+		t.Fatalf("Expected 0 members, got %d", c.MembersCount())
+	}
+	if c.Has("server1") {
+		t.Fatal("Expected server1 to be absent")
+	}
+
+	c.Add(testWeightedMember{name: "server1", weight: 2})
+	c.Add(testWeightedMember{name: "server2", weight: 1})
+	if c.MembersCount() != 2 {
+		t.Fatalf("Expected 2 members, got %d", c.MembersCount())
+	}
+	if !c.Has("server1") || !c.Has("server2") {
+		t.Fatal("Expected server1 and server2 to be present")
+	}
+
+	c.Remove("server1")
+	if c.MembersCount() != 1 {
+		t.Fatalf("Expected 1 member, got %d", c.MembersCount())
+	}
+	if c.Has("server1") {
+		t.Fatal("Expected server1 to be absent after remove")
+	}
+}
+
+func TestWeightedConsistent_UpdateWeight(t *testing.T) {
+	members := make([]WeightedMember, 0, 10)
+	for i := 0; i < 10; i++ {
 		members = append(members, testWeightedMember{
-			name:   fmt.Sprintf("server%d", i),
-			weight: weight,
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: 2,
 		})
 	}
 
 	cfg := WeightedConfig{
 		PartitionCount:    271,
-		ReplicationFactor: 20,
+		ReplicationFactor: 10,
 		Load:              1.25,
 		Hasher:            testWeightedHasher{},
 	}
 
+	owners := func(c *WeightedConsistent) map[int]string {
+		res := make(map[int]string)
+		for partID := 0; partID < cfg.PartitionCount; partID++ {
+			res[partID] = c.GetPartitionOwner(partID).String()
+		}
+		return res
+	}
+	moved := func(before, after map[int]string) int {
+		var count int
+		for partID, owner := range before {
+			if after[partID] != owner {
+				count++
+			}
+		}
+		return count
+	}
+
 	c := NewWeighted(members, cfg)
-	key := []byte("benchmark-key")
+	if err := c.UpdateWeight("nonexistent", 3); err != ErrMemberNotFound {
+		t.Fatalf("Expected ErrMemberNotFound, got %v", err)
+	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		c.LocateKey(key)
+	before := owners(c)
+	if err := c.UpdateWeight("node3.olric", 4); err != nil {
+		t.Fatalf("UpdateWeight returned error: %v", err)
+	}
+	if c.WeightDistribution()["node3.olric"] != 4 {
+		t.Fatalf("Expected weight 4, got %d", c.WeightDistribution()["node3.olric"])
+	}
+	if c.GetTotalWeight() != 22 {
+		t.Fatalf("Expected total weight 22, got %d", c.GetTotalWeight())
+	}
+	if len(c.sortedSet) != 220 {
+		t.Fatalf("Expected 220 positions on the ring, got %d", len(c.sortedSet))
+	}
+	updateChurn := moved(before, owners(c))
+
+	// The same change with Remove and Add.
+	other := NewWeighted(members, cfg)
+	before = owners(other)
+	other.Remove("node3.olric")
+	intermediate := owners(other)
+	other.Add(testWeightedMember{name: "node3.olric", weight: 4})
+	removeAddChurn := moved(before, intermediate) + moved(intermediate, owners(other))
+
+	if updateChurn >= removeAddChurn {
+		t.Fatalf("Expected less churn than remove+add: %d >= %d", updateChurn, removeAddChurn)
+	}
+
+	if err := c.UpdateWeight("node3.olric", 0); err != nil {
+		t.Fatalf("UpdateWeight returned error: %v", err)
+	}
+	if c.WeightDistribution()["node3.olric"] != 1 {
+		t.Fatalf("Expected weight to be clamped to 1, got %d", c.WeightDistribution()["node3.olric"])
+	}
+	if c.GetTotalWeight() != 19 {
+		t.Fatalf("Expected total weight 19, got %d", c.GetTotalWeight())
+	}
+	if len(c.sortedSet) != 190 {
+		t.Fatalf("Expected 190 positions on the ring, got %d", len(c.sortedSet))
+	}
+	avgLoad := c.AverageLoad()
+	for name, load := range c.LoadDistribution() {
+		if load > avgLoad*float64(c.WeightDistribution()[name]) {
+			t.Fatalf("%s exceeds its expected load: %.0f", name, load)
+		}
+	}
+}
+
+func TestWeightedConsistent_NotEnoughRoom(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+	}
+
+	// Partition 0 weighs more than Load times the fair share of any member.
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		PartitionWeightFunc: func(partID int) float64 {
+			if partID == 0 {
+				return 1000
+			}
+			return 1
+		},
+	}
+
+	c, err := NewWeightedChecked(members, cfg)
+	if !errors.Is(err, ErrNotEnoughRoom) {
+		t.Fatalf("Expected ErrNotEnoughRoom, got %v", err)
+	}
+	if c != nil {
+		t.Fatal("Expected nil ring on error")
+	}
+
+	c, err = NewWeightedChecked(members[1:], cfg)
+	if err != nil {
+		t.Fatalf("NewWeightedChecked returned error: %v", err)
+	}
+	if err := c.AddChecked(members[0]); !errors.Is(err, ErrNotEnoughRoom) {
+		t.Fatalf("Expected ErrNotEnoughRoom, got %v", err)
+	}
+	if len(c.GetMembers()) != 1 || c.GetTotalWeight() != 1 || len(c.sortedSet) != 10 {
+		t.Fatal("Expected the ring to be unchanged after a failed add")
+	}
+
+	defer func() {
+		if r, _ := recover().(error); !errors.Is(r, ErrNotEnoughRoom) {
+			t.Fatalf("Expected Add to panic with ErrNotEnoughRoom, got %v", r)
+		}
+	}()
+	c.Add(members[0])
+}
+
+func TestWeightedConsistent_InvalidLoad(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 2},
+		testWeightedMember{name: "node2.olric", weight: 1},
+	}
+	for _, load := range []float64{1, 0.5, -1} {
+		cfg := WeightedConfig{
+			PartitionCount:    71,
+			ReplicationFactor: 10,
+			Load:              load,
+			Hasher:            testWeightedHasher{},
+		}
+		c, err := NewWeightedChecked(members, cfg)
+		if err != ErrInvalidLoad {
+			t.Fatalf("Expected ErrInvalidLoad for Load %v, got %v", load, err)
+		}
+		if c != nil {
+			t.Fatal("Expected nil ring on error")
+		}
+	}
+
+	// Load isn't used without bounded loads.
+	_, err := NewWeightedChecked(members, WeightedConfig{
+		PartitionCount:     71,
+		ReplicationFactor:  10,
+		Load:               1,
+		Hasher:             testWeightedHasher{},
+		DisableBoundedLoad: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r != ErrInvalidLoad {
+			t.Fatalf("Expected NewWeighted to panic with ErrInvalidLoad, got %v", r)
+		}
+	}()
+	NewWeighted(members, WeightedConfig{Load: 1})
+}
+
+func TestWeightedConsistent_NewWeightedChecked(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+	}
+
+	if _, err := NewWeightedChecked(members, cfg); err != nil {
+		t.Fatalf("Expected DefaultHasher for a nil hasher, got: %v", err)
+	}
+
+	cfg.Hasher = testWeightedHasher{}
+	c, err := NewWeightedChecked(members, cfg)
+	if err != nil {
+		t.Fatalf("NewWeightedChecked returned error: %v", err)
+	}
+	if err := c.AddChecked(testWeightedMember{name: "server3", weight: 1}); err != nil {
+		t.Fatalf("AddChecked returned error: %v", err)
+	}
+	if len(c.GetMembers()) != 3 {
+		t.Fatalf("Expected 3 members, got %d", len(c.GetMembers()))
+	}
+}
+
+func TestWeightedConsistent_PartitionTable(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+		testWeightedMember{name: "server3", weight: 3},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	table := c.PartitionTable()
+	if len(table) != cfg.PartitionCount {
+		t.Fatalf("Expected %d partitions in table, got %d", cfg.PartitionCount, len(table))
+	}
+	for partID, owner := range table {
+		if owner != c.GetPartitionOwner(partID).String() {
+			t.Fatalf("Expected %s to own partition %d, got %s", c.GetPartitionOwner(partID), partID, owner)
+		}
+	}
+
+	// The table is a copy.
+	table[0] = "modified"
+	if c.PartitionTable()[0] == "modified" {
+		t.Fatal("Modifying the returned table changed the ring")
+	}
+
+	if len(NewWeighted(nil, cfg).PartitionTable()) != 0 {
+		t.Fatal("Expected an empty table for an empty ring")
+	}
+}
+
+func TestWeightedConsistent_Seed(t *testing.T) {
+	members := make([]WeightedMember, 0, 4)
+	for i := 0; i < 4; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		Seed:              1,
+	}
+
+	c1 := NewWeighted(members, cfg)
+	same := NewWeighted(members, cfg)
+	cfg.Seed = 2
+	c2 := NewWeighted(members, cfg)
+
+	var differ int
+	for i := 0; i < 10000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if c1.LocateKey(key).String() != same.LocateKey(key).String() {
+			t.Fatalf("Rings with the same seed disagree on %s", key)
+		}
+		if c1.LocateKey(key).String() != c2.LocateKey(key).String() {
+			differ++
+		}
+	}
+
+	// Independent placements over 4 equal members disagree on about 3/4 of the keys.
+	if ratio := float64(differ) / 10000; ratio < 0.5 {
+		t.Fatalf("Expected rings with different seeds to disagree on most keys, got %.2f", ratio)
+	}
+}
+
+func TestWeightedConsistent_EachPartition(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	next := 0
+	c.EachPartition(func(partID int, owner WeightedMember) bool {
+		if partID != next {
+			t.Fatalf("Expected partition %d, got %d", next, partID)
+		}
+		if owner.String() != c.getPartitionOwner(partID).String() {
+			t.Fatalf("Expected %s to own partition %d, got %s", c.getPartitionOwner(partID), partID, owner)
+		}
+		next++
+		return true
+	})
+	if next != cfg.PartitionCount {
+		t.Fatalf("Expected %d partitions, got %d", cfg.PartitionCount, next)
+	}
+
+	var visited int
+	c.EachPartition(func(partID int, owner WeightedMember) bool {
+		visited++
+		return partID < 9
+	})
+	if visited != 10 {
+		t.Fatalf("Expected the iteration to stop after 10 partitions, got %d", visited)
+	}
+
+	NewWeighted(nil, cfg).EachPartition(func(partID int, owner WeightedMember) bool {
+		t.Fatal("Expected no partitions on an empty ring")
+		return false
+	})
+}
+
+func TestWeightedConsistent_OnPartitionMoved(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	moved := make(map[int][2]string)
+	c.OnPartitionMoved(func(partID int, from, to string) {
+		if _, ok := moved[partID]; ok {
+			t.Fatalf("Partition %d reported twice", partID)
+		}
+		moved[partID] = [2]string{from, to}
+	})
+
+	check := func(before map[int]string) {
+		t.Helper()
+		after := c.PartitionTable()
+		for partID := 0; partID < cfg.PartitionCount; partID++ {
+			move, ok := moved[partID]
+			if before[partID] == after[partID] {
+				if ok {
+					t.Fatalf("Partition %d reported but its owner didn't change", partID)
+				}
+				continue
+			}
+			if !ok {
+				t.Fatalf("Partition %d moved from %s to %s without a callback", partID, before[partID], after[partID])
+			}
+			if move[0] != before[partID] || move[1] != after[partID] {
+				t.Fatalf("Expected partition %d to move from %s to %s, got %s to %s",
+					partID, before[partID], after[partID], move[0], move[1])
+			}
+		}
+		for k := range moved {
+			delete(moved, k)
+		}
+	}
+
+	before := c.PartitionTable()
+	c.Add(testWeightedMember{name: "node8.olric", weight: 2})
+	if len(moved) == 0 {
+		t.Fatal("Expected some partitions to move to the new member")
+	}
+	check(before)
+
+	before = c.PartitionTable()
+	c.Remove("node3.olric")
+	check(before)
+
+	before = c.PartitionTable()
+	if err := c.UpdateWeight("node1.olric", 4); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	check(before)
+
+	// Adding a member which is already in the ring changes nothing.
+	c.Add(testWeightedMember{name: "node8.olric", weight: 2})
+	if len(moved) != 0 {
+		t.Fatalf("Expected no moves, got %d", len(moved))
+	}
+
+	c.OnPartitionMoved(nil)
+	c.Remove("node8.olric")
+	if len(moved) != 0 {
+		t.Fatalf("Expected no callbacks after unregistering, got %d", len(moved))
+	}
+}
+
+func TestWeightedConsistent_PartitionRanges(t *testing.T) {
+	members := make([]WeightedMember, 0, 6)
+	for i := 0; i < 6; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	ranges := c.PartitionRanges()
+	if len(ranges) != cfg.PartitionCount {
+		t.Fatalf("Expected %d ranges, got %d", cfg.PartitionCount, len(ranges))
+	}
+	ownerRanges := c.OwnerRanges()
+
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		residue := c.hasher.Sum64(key) % uint64(cfg.PartitionCount)
+
+		var partIDs []int
+		for partID, r := range ranges {
+			if residue >= r[0] && residue < r[1] {
+				partIDs = append(partIDs, partID)
+			}
+		}
+		if len(partIDs) != 1 {
+			t.Fatalf("Expected %s to fall in exactly one range, got %v", key, partIDs)
+		}
+		if partIDs[0] != c.FindPartitionID(key) {
+			t.Fatalf("Expected partition %d for %s, got %d", c.FindPartitionID(key), key, partIDs[0])
+		}
+
+		var owners []string
+		for name, rs := range ownerRanges {
+			for _, r := range rs {
+				if residue >= r[0] && residue < r[1] {
+					owners = append(owners, name)
+				}
+			}
+		}
+		if len(owners) != 1 || owners[0] != c.LocateKey(key).String() {
+			t.Fatalf("Expected %s to be owned by %s, got %v", key, c.LocateKey(key), owners)
+		}
+	}
+
+	var covered uint64
+	for _, rs := range ownerRanges {
+		for i, r := range rs {
+			if i > 0 && rs[i-1][1] >= r[0] {
+				t.Fatalf("Expected sorted and merged ranges, got %v", rs)
+			}
+			covered += r[1] - r[0]
+		}
+	}
+	if covered != uint64(cfg.PartitionCount) {
+		t.Fatalf("Expected the owner ranges to cover %d partitions, got %d", cfg.PartitionCount, covered)
+	}
+}
+
+func TestWeightedConsistent_Clone(t *testing.T) {
+	members := make([]WeightedMember, 0, 6)
+	for i := 0; i < 6; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	table := c.PartitionTable()
+	loads := c.LoadDistribution()
+	weights := c.WeightDistribution()
+	ringSize := len(c.sortedSet)
+
+	clone := c.Clone()
+	if !reflect.DeepEqual(clone.PartitionTable(), table) {
+		t.Fatal("Expected the clone to have the same partition table")
+	}
+
+	clone.Add(testWeightedMember{name: "node6.olric", weight: 3})
+	clone.Remove("node0.olric")
+	if err := clone.UpdateWeight("node1.olric", 5); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if reflect.DeepEqual(clone.PartitionTable(), table) {
+		t.Fatal("Expected the partition table of the clone to change")
+	}
+
+	if !reflect.DeepEqual(c.PartitionTable(), table) {
+		t.Fatal("Partition table of the original changed")
+	}
+	if !reflect.DeepEqual(c.LoadDistribution(), loads) {
+		t.Fatal("Loads of the original changed")
+	}
+	if !reflect.DeepEqual(c.WeightDistribution(), weights) {
+		t.Fatal("Weights of the original changed")
+	}
+	if len(c.sortedSet) != ringSize || len(c.ring) != ringSize {
+		t.Fatalf("Expected the original ring size %d, got %d", ringSize, len(c.sortedSet))
+	}
+	if c.Has("node6.olric") || !c.Has("node0.olric") {
+		t.Fatal("Members of the original changed")
+	}
+	if c.GetTotalWeight() != 12 {
+		t.Fatalf("Expected total weight 12, got %d", c.GetTotalWeight())
+	}
+}
+
+func TestSuggestPartitionCount(t *testing.T) {
+	prev := 0
+	for _, memberCount := range []int{0, 1, 3, 10, 50, 100, 500, 1000, 5000} {
+		for _, load := range []float64{1.1, 1.25, 2} {
+			n := SuggestPartitionCount(memberCount, load)
+			if !isPrime(n) {
+				t.Fatalf("Expected a prime for %d members and load %f, got %d", memberCount, load, n)
+			}
+			if n < DefaultPartitionCount {
+				t.Fatalf("Expected at least %d partitions, got %d", DefaultPartitionCount, n)
+			}
+			if headroom := float64(n) / float64(memberCount) * (load - 1); memberCount > 0 && headroom < 10 {
+				t.Fatalf("Expected at least 10 partitions of headroom for %d members and load %f, got %f",
+					memberCount, load, headroom)
+			}
+		}
+		n := SuggestPartitionCount(memberCount, DefaultLoad)
+		if n < prev {
+			t.Fatalf("Expected the suggestion to grow with the member count, got %d after %d", n, prev)
+		}
+		prev = n
+	}
+
+	if SuggestPartitionCount(100, 1) != SuggestPartitionCount(100, DefaultLoad) {
+		t.Fatal("Expected DefaultLoad for a load factor of 1")
+	}
+}
+
+func TestWeightedConsistent_AutoPartitionCount(t *testing.T) {
+	members := make([]WeightedMember, 0, 100)
+	for i := 0; i < 100; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+
+	c := NewWeighted(members, WeightedConfig{
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	})
+	if expected := SuggestPartitionCount(100, 1.25); c.PartitionCount() != expected {
+		t.Fatalf("Expected %d partitions, got %d", expected, c.PartitionCount())
+	}
+
+	c = NewWeighted(nil, WeightedConfig{Hasher: testWeightedHasher{}})
+	if c.PartitionCount() != DefaultPartitionCount {
+		t.Fatalf("Expected %d partitions, got %d", DefaultPartitionCount, c.PartitionCount())
+	}
+}
+
+func TestWeightedConsistent_LocateKeyConcurrentWithMutations(t *testing.T) {
+	members := make([]WeightedMember, 0, 10)
+	for i := 0; i < 10; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			name := fmt.Sprintf("node%d.olric", 10+i%5)
+			c.Add(testWeightedMember{name: name, weight: i%3 + 1})
+			c.Remove(name)
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := []byte(fmt.Sprintf("key-%d", j))
+				if c.LocateKey(key) == nil {
+					t.Errorf("Expected to find a member for %s", key)
+					return
+				}
+				if c.GetPartitionOwner(c.FindPartitionID(key)) == nil {
+					t.Errorf("Expected an owner for %s", key)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c.GetPartitionOwner(-1) != nil || c.GetPartitionOwner(cfg.PartitionCount) != nil {
+		t.Fatal("Expected nil for an invalid partition ID")
+	}
+	table := c.PartitionTable()
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID).String() != table[partID] {
+			t.Fatalf("Expected %s to own partition %d, got %s", table[partID], partID, c.GetPartitionOwner(partID))
+		}
+	}
+}
+
+func TestWeightedConsistent_DisableBoundedLoad(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 4},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:     4099,
+		ReplicationFactor:  20,
+		Load:               0.5,
+		Hasher:             testWeightedHasher{},
+		DisableBoundedLoad: true,
+	}
+
+	c, err := NewWeightedChecked(members, cfg)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	checkSuccessors := func() {
+		t.Helper()
+		for partID := 0; partID < cfg.PartitionCount; partID++ {
+			successor := (*c.ring[c.sortedSet[c.partitionIndex(partID)]]).String()
+			if owner := c.GetPartitionOwner(partID).String(); owner != successor {
+				t.Fatalf("Expected %s to own partition %d, got %s", successor, partID, owner)
+			}
+		}
+	}
+	checkSuccessors()
+
+	loads := c.LoadDistribution()
+	if loads["node2.olric"] <= 2*loads["node1.olric"] {
+		t.Fatalf("Expected the replica counts to favor node2.olric, got %v", loads)
+	}
+
+	c.Add(testWeightedMember{name: "node3.olric", weight: 2})
+	checkSuccessors()
+	c.Remove("node2.olric")
+	checkSuccessors()
+
+	// An empty ring has no successors.
+	empty, err := NewWeightedChecked([]WeightedMember{}, cfg)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if err := empty.distributeWithLoad(0, 0, make(map[int]*WeightedMember), make(map[string]float64)); !errors.Is(err, ErrNotEnoughRoom) {
+		t.Fatalf("Expected ErrNotEnoughRoom, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_RingSize(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 3},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	if c.RingSize() != 40 {
+		t.Fatalf("Expected ring size 40, got %d", c.RingSize())
+	}
+	if c.ReplicaCount("node1.olric") != 10 {
+		t.Fatalf("Expected 10 replicas, got %d", c.ReplicaCount("node1.olric"))
+	}
+	if c.ReplicaCount("node2.olric") != 30 {
+		t.Fatalf("Expected 30 replicas, got %d", c.ReplicaCount("node2.olric"))
+	}
+	if c.ReplicaCount("unknown") != 0 {
+		t.Fatalf("Expected 0 replicas for an unknown member, got %d", c.ReplicaCount("unknown"))
+	}
+
+	if err := c.UpdateWeight("node2.olric", 2); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	c.Remove("node1.olric")
+	if c.RingSize() != 20 || c.ReplicaCount("node2.olric") != 20 {
+		t.Fatalf("Expected ring size 20, got %d", c.RingSize())
+	}
+}
+
+func TestWeightedConsistent_FindPartitionIDConcurrentWithAdd(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted([]WeightedMember{testWeightedMember{name: "node0.olric", weight: 1}}, cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i < 30; i++ {
+			c.Add(testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := []byte(fmt.Sprintf("key-%d", j))
+				expected := int(testWeightedHasher{}.Sum64(key) % uint64(cfg.PartitionCount))
+				if partID := c.FindPartitionID(key); partID != expected {
+					t.Errorf("Expected partition %d for %s, got %d", expected, key, partID)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestWeightedConsistent_SetPartitionCount(t *testing.T) {
+	members := make([]WeightedMember, 0, 6)
+	for i := 0; i < 6; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	old := c.Clone()
+
+	moved := make(map[int]struct{})
+	c.OnPartitionMoved(func(partID int, from, to string) {
+		moved[partID] = struct{}{}
+	})
+
+	if err := c.SetPartitionCount(1021); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.PartitionCount() != 1021 {
+		t.Fatalf("Expected 1021 partitions, got %d", c.PartitionCount())
+	}
+	if len(c.PartitionTable()) != 1021 {
+		t.Fatalf("Expected 1021 owned partitions, got %d", len(c.PartitionTable()))
+	}
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		expected := int(testWeightedHasher{}.Sum64(key) % 1021)
+		if partID := c.FindPartitionID(key); partID != expected {
+			t.Fatalf("Expected partition %d for %s, got %d", expected, key, partID)
+		}
+		if c.LocateKey(key).String() != c.GetPartitionOwner(expected).String() {
+			t.Fatalf("Expected %s to be owned by the owner of partition %d", key, expected)
+		}
+	}
+	if plan := MigrationPlan(old, c); len(plan) != len(moved) {
+		t.Fatalf("Expected %d moves, got %d", len(plan), len(moved))
+	}
+	if old.PartitionCount() != 71 {
+		t.Fatal("Partition count of the clone changed")
+	}
+
+	if err := c.SetPartitionCount(0); err != ErrInvalidPartitionCount {
+		t.Fatalf("Expected ErrInvalidPartitionCount, got: %v", err)
+	}
+	if err := c.SetPartitionCount(100000); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	// Shrinking drops the partitions above the new count.
+	if err := c.SetPartitionCount(71); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if len(c.PartitionTable()) != 71 || c.FindPartitionID([]byte("key")) >= 71 {
+		t.Fatalf("Expected 71 partitions, got %d", len(c.PartitionTable()))
+	}
+
+	empty := NewWeighted(nil, cfg)
+	if err := empty.SetPartitionCount(271); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	empty.Add(testWeightedMember{name: "node0.olric", weight: 1})
+	if len(empty.PartitionTable()) != 271 {
+		t.Fatalf("Expected 271 owned partitions, got %d", len(empty.PartitionTable()))
+	}
+}
+
+func TestWeightedConsistent_SetPartitionCountConcurrentClosestN(t *testing.T) {
+	members := make([]WeightedMember, 0, 6)
+	for i := 0; i < 6; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    1021,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	// The partition ID is computed under the same lock as the replicas, so shrinking the partition count
+	// concurrently doesn't make it out of range.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				key := []byte(fmt.Sprintf("key-%d", n))
+				if _, err := c.GetClosestNWithDistance(key, 2); err != nil {
+					t.Errorf("Expected nil, got: %v", err)
+					return
+				}
+				if _, err := c.GetClosestN(key, 2); err != nil {
+					t.Errorf("Expected nil, got: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		count := 71
+		if i%2 == 1 {
+			count = 1021
+		}
+		if err := c.SetPartitionCount(count); err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestWeightedConsistent_MinimalDisruption(t *testing.T) {
+	members := make([]WeightedMember, 0, 20)
+	for i := 0; i < 20; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    1021,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            DefaultHasher{},
+		MinimalDisruption: true,
+	}
+
+	c := NewWeighted(members, cfg)
+	old := c.Clone()
+	c.Add(testWeightedMember{name: "node20.olric", weight: 1})
+
+	plan := MigrationPlan(old, c)
+	for _, move := range plan {
+		if move.To != "node20.olric" {
+			t.Fatalf("Expected partition %d to stay on %s, moved to %s", move.PartitionID, move.From, move.To)
+		}
+	}
+	fraction := float64(len(plan)) / float64(cfg.PartitionCount)
+	if fraction < 0.8/21 || fraction > cfg.Load/21 {
+		t.Fatalf("Expected about 1/21 of the partitions to move, got %f", fraction)
+	}
+	if overloaded := c.OverloadedMembers(); len(overloaded) != 0 {
+		t.Fatalf("Expected no overloaded members, got %v", overloaded)
+	}
+
+	cfg.MinimalDisruption = false
+	greedy := NewWeighted(members, cfg)
+	greedy.Add(testWeightedMember{name: "node20.olric", weight: 1})
+	if greedyPlan := MigrationPlan(old, greedy); len(plan) > len(greedyPlan) {
+		t.Fatalf("Expected at most %d moves, got %d", len(greedyPlan), len(plan))
+	}
+}
+
+func TestWeightedConsistent_GetClosestNExcludingOwner(t *testing.T) {
+	members := make([]WeightedMember, 0, 6)
+	for i := 0; i < 6; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	for i := 0; i < 200; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		owner := c.LocateKey(key)
+
+		replicas, err := c.GetClosestNExcludingOwner(key, 3)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if len(replicas) != 3 {
+			t.Fatalf("Expected 3 members, got %d", len(replicas))
+		}
+		closest, err := c.GetClosestN(key, 4)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		for j, member := range replicas {
+			if member.String() == owner.String() {
+				t.Fatalf("Owner %s returned for %s", owner, key)
+			}
+			if member.String() != closest[j+1].String() {
+				t.Fatalf("Expected %s at %d, got %s", closest[j+1], j, member)
+			}
+		}
+
+		all, err := c.GetClosestNExcludingOwner(key, len(members)-1)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		for _, member := range all {
+			if member.String() == owner.String() {
+				t.Fatalf("Owner %s returned for %s", owner, key)
+			}
+		}
+	}
+
+	if _, err := c.GetClosestNExcludingOwner([]byte("key"), len(members)); err != ErrInsufficientMemberCount {
+		t.Fatalf("Expected ErrInsufficientMemberCount, got: %v", err)
+	}
+}
+
+type testMetricsObserver struct {
+	redistributions int
+	moved           int
+	memberCounts    []int
+}
+
+func (o *testMetricsObserver) ObserveRedistribution(duration time.Duration, partitionsMoved int) {
+	o.redistributions++
+	o.moved += partitionsMoved
+}
+
+func (o *testMetricsObserver) ObserveMemberCount(n int) {
+	o.memberCounts = append(o.memberCounts, n)
+}
+
+func TestWeightedConsistent_MetricsObserver(t *testing.T) {
+	observer := &testMetricsObserver{}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		MetricsObserver:   observer,
+	}
+
+	c := NewWeighted([]WeightedMember{testWeightedMember{name: "node0.olric", weight: 1}}, cfg)
+	if observer.redistributions != 1 || observer.moved != cfg.PartitionCount {
+		t.Fatalf("Expected 1 redistribution moving %d partitions, got %d moving %d",
+			cfg.PartitionCount, observer.redistributions, observer.moved)
+	}
+
+	old := c.Clone()
+	observer.moved = 0
+	c.Add(testWeightedMember{name: "node1.olric", weight: 2})
+	if observer.redistributions != 2 {
+		t.Fatalf("Expected 2 redistributions, got %d", observer.redistributions)
+	}
+	if plan := MigrationPlan(old, c); observer.moved != len(plan) {
+		t.Fatalf("Expected %d moved partitions, got %d", len(plan), observer.moved)
+	}
+
+	// Adding an existing member doesn't redistribute.
+	c.Add(testWeightedMember{name: "node1.olric", weight: 2})
+	c.Remove("node0.olric")
+	c.Remove("node1.olric")
+	if observer.redistributions != 4 {
+		t.Fatalf("Expected 4 redistributions, got %d", observer.redistributions)
+	}
+	if !reflect.DeepEqual(observer.memberCounts, []int{1, 2, 1, 0}) {
+		t.Fatalf("Expected member counts [1 2 1 0], got %v", observer.memberCounts)
+	}
+
+	// The observer is optional.
+	cfg.MetricsObserver = nil
+	c = NewWeighted([]WeightedMember{testWeightedMember{name: "node0.olric", weight: 1}}, cfg)
+	c.Add(testWeightedMember{name: "node1.olric", weight: 2})
+}
+
+func TestWeightedConsistent_RemoveMovesToSuccessors(t *testing.T) {
+	members := make([]WeightedMember, 0, 20)
+	for i := 0; i < 20; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    1021,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            DefaultHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	old := c.Clone()
+	removed := "node7.olric"
+	owned := len(c.OwnedPartitions(removed))
+	c.Remove(removed)
+
+	plan := MigrationPlan(old, c)
+	if len(plan) != owned {
+		t.Fatalf("Expected only the %d partitions of %s to move, got %d", owned, removed, len(plan))
+	}
+	for _, move := range plan {
+		if move.From != removed {
+			t.Fatalf("Expected partition %d to stay on %s, moved to %s", move.PartitionID, move.From, move.To)
+		}
+		// The new owner is the first member on the ring after the partition that has room for it.
+		idx := c.partitionIndex(move.PartitionID)
+		for {
+			name := (*c.ring[c.sortedSet[idx]]).String()
+			if name == move.To {
+				break
+			}
+			if c.loads[name] < c.ExpectedLoad(name) {
+				t.Fatalf("Expected partition %d to go to %s, moved to %s", move.PartitionID, name, move.To)
+			}
+			idx = (idx + 1) % len(c.sortedSet)
+		}
+	}
+
+	// A full rebuild of the same ring scatters many more partitions.
+	rebuilt := c.Clone()
+	if err := rebuilt.distributePartitions(context.Background()); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if full := MigrationPlan(old, rebuilt); len(full) <= len(plan) {
+		t.Fatalf("Expected the full rebuild to move more than %d partitions, got %d", len(plan), len(full))
+	}
+}
+
+func TestWeightedConsistent_OwnerChanged(t *testing.T) {
+	members := make([]WeightedMember, 0, 4)
+	for i := 0; i < 4; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	previous := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		previous[key] = c.LocateKey([]byte(key)).String()
+		if c.OwnerChanged([]byte(key), previous[key]) {
+			t.Fatalf("Expected the owner of %s not to change", key)
+		}
+	}
+
+	c.Add(testWeightedMember{name: "node4.olric", weight: 2})
+	var moved int
+	for key, owner := range previous {
+		changed := c.OwnerChanged([]byte(key), owner)
+		if changed != (c.LocateKey([]byte(key)).String() != owner) {
+			t.Fatalf("OwnerChanged is inconsistent with LocateKey for %s", key)
+		}
+		if changed {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Fatal("Expected some keys to move to the new member")
+	}
+
+	empty := NewWeighted(nil, cfg)
+	if empty.OwnerChanged([]byte("key"), "") || !empty.OwnerChanged([]byte("key"), "node0.olric") {
+		t.Fatal("Expected an empty ring to have no owner")
+	}
+}
+
+func TestWeightedConsistent_StableTieBreak(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            DefaultHasher{},
+		StableTieBreak:    true,
+	}
+
+	c1 := NewWeighted(members, cfg)
+	cfg.Hasher = testWeightedHasher{}
+	c2 := NewWeighted(members, cfg)
+
+	if !reflect.DeepEqual(c1.PartitionTable(), c2.PartitionTable()) {
+		t.Fatal("Expected the same partition table with different hashers")
+	}
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		// Partition IDs are computed by the hashers, compare the owners of the same partition.
+		partID := c1.FindPartitionID(key)
+		if c1.LocateKey(key).String() != c2.GetPartitionOwner(partID).String() {
+			t.Fatalf("Expected the same owner for partition %d", partID)
+		}
+	}
+
+	c1.Add(testWeightedMember{name: "node8.olric", weight: 2})
+	c2.Add(testWeightedMember{name: "node8.olric", weight: 2})
+	if !reflect.DeepEqual(c1.PartitionTable(), c2.PartitionTable()) {
+		t.Fatal("Expected the same partition table after Add")
+	}
+	if len(c1.OwnedPartitions("node8.olric")) == 0 {
+		t.Fatal("Expected the new member to take over some partitions")
+	}
+	c1.Remove("node3.olric")
+	c2.Remove("node3.olric")
+	if !reflect.DeepEqual(c1.PartitionTable(), c2.PartitionTable()) {
+		t.Fatal("Expected the same partition table after Remove")
+	}
+	if overloaded := c1.OverloadedMembers(); len(overloaded) != 0 {
+		t.Fatalf("Expected no overloaded members, got %v", overloaded)
+	}
+}
+
+func TestWeightedConsistent_MaxReplicasPerMember(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:       71,
+		ReplicationFactor:    10,
+		Load:                 1.25,
+		Hasher:               testWeightedHasher{},
+		MaxReplicasPerMember: 50,
+	}
+
+	members := []WeightedMember{testWeightedMember{name: "node1.olric", weight: 6}}
+	if _, err := NewWeightedChecked(members, cfg); err != ErrTooManyReplicas {
+		t.Fatalf("Expected ErrTooManyReplicas, got: %v", err)
+	}
+
+	members = []WeightedMember{testWeightedMember{name: "node1.olric", weight: 5}}
+	c, err := NewWeightedChecked(members, cfg)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	if err := c.AddChecked(testWeightedMember{name: "node2.olric", weight: 1000}); err != ErrTooManyReplicas {
+		t.Fatalf("Expected ErrTooManyReplicas, got: %v", err)
+	}
+	if c.Has("node2.olric") || c.RingSize() != 50 {
+		t.Fatalf("Expected the ring to be unchanged, got %d positions", c.RingSize())
+	}
+
+	if err := c.UpdateWeight("node1.olric", 6); err != ErrTooManyReplicas {
+		t.Fatalf("Expected ErrTooManyReplicas, got: %v", err)
+	}
+	if c.ReplicaCount("node1.olric") != 50 {
+		t.Fatalf("Expected 50 replicas, got %d", c.ReplicaCount("node1.olric"))
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != ErrTooManyReplicas {
+				t.Fatalf("Expected a panic with ErrTooManyReplicas, got: %v", r)
+			}
+		}()
+		c.AddMany([]WeightedMember{
+			testWeightedMember{name: "node2.olric", weight: 1},
+			testWeightedMember{name: "node3.olric", weight: 10},
+		})
+	}()
+	if c.Has("node2.olric") {
+		t.Fatal("Expected AddMany to leave the ring unchanged")
+	}
+
+	if err := c.AddChecked(testWeightedMember{name: "node2.olric", weight: 5}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+}
+
+func TestWeightedConsistent_MinReplicasPerMember(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:       271,
+		ReplicationFactor:    2,
+		Load:                 1.25,
+		Hasher:               testWeightedHasher{},
+		MinReplicasPerMember: 40,
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "light", weight: 1},
+		testWeightedMember{name: "heavy1", weight: 30},
+		testWeightedMember{name: "heavy2", weight: 30},
+		testWeightedMember{name: "heavy3", weight: 30},
+	}, cfg)
+
+	if c.ReplicaCount("light") != 40 || len(c.MemberPositions("light")) != 40 {
+		t.Fatalf("Expected 40 replicas of light, got %d", c.ReplicaCount("light"))
+	}
+	if c.ReplicaCount("heavy1") != 60 || c.RingSize() != 40+3*60 {
+		t.Fatalf("Expected 60 replicas of heavy1 and 220 positions, got %d and %d",
+			c.ReplicaCount("heavy1"), c.RingSize())
+	}
+
+	// The loads stay proportional to the weights: light may own a few partitions, but it's bounded
+	// by its share of the total weight.
+	loads := c.LoadDistribution()
+	maxLoad := math.Ceil(float64(cfg.PartitionCount) / 91 * cfg.Load)
+	if loads["light"] == 0 || loads["light"] > maxLoad {
+		t.Fatalf("Expected light to own between 1 and %v partitions, got %v", maxLoad, loads["light"])
+	}
+	for _, name := range []string{"heavy1", "heavy2", "heavy3"} {
+		if loads[name] <= loads["light"] {
+			t.Fatalf("Expected %s to own more partitions than light, got %v and %v", name, loads[name], loads["light"])
+		}
+	}
+
+	// Only the replicas above the minimum follow the weight.
+	if err := c.UpdateWeight("light", 25); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.ReplicaCount("light") != 50 || c.RingSize() != 50+3*60 {
+		t.Fatalf("Expected 50 replicas of light, got %d", c.ReplicaCount("light"))
+	}
+	if err := c.UpdateWeight("light", 1); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.ReplicaCount("light") != 40 || c.RingSize() != 40+3*60 {
+		t.Fatalf("Expected 40 replicas of light, got %d", c.ReplicaCount("light"))
+	}
+
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	restored, err := RestoreWeighted(data, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !restored.Equal(c) {
+		t.Fatal("Expected the restored ring to be equal")
+	}
+
+	c.Remove("light")
+	if c.ReplicaCount("light") != 0 || c.RingSize() != 3*60 {
+		t.Fatalf("Expected 180 positions, got %d", c.RingSize())
+	}
+}
+
+func TestWeightedConsistent_PartitionWeightFunc(t *testing.T) {
+	const heavyPartition = 0
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.1,
+		Hasher:            testWeightedHasher{},
+		PartitionWeightFunc: func(partID int) float64 {
+			if partID == heavyPartition {
+				return 20
+			}
+			return 1
+		},
+	}
+	var members []WeightedMember
+	for i := 0; i < 4; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+	c := NewWeighted(members, cfg)
+
+	// 70 partitions weigh 1 and one partition weighs 20, the average load is ceil(90/4*1.1) = 25.
+	loads := c.LoadDistribution()
+	var total float64
+	for name, load := range loads {
+		if load > 25 {
+			t.Fatalf("Expected the load of %s to be at most 25, got %v", name, load)
+		}
+		total += load
+	}
+	if total != 90 {
+		t.Fatalf("Expected the total load to be 90, got %v", total)
+	}
+
+	// The owner of the heavy partition must own fewer partitions than the others.
+	heavy := c.GetPartitionOwner(heavyPartition).String()
+	counts := make(map[string]int)
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		counts[c.GetPartitionOwner(partID).String()]++
+	}
+	if counts[heavy] > 6 {
+		t.Fatalf("Expected %s to own at most 6 partitions, got %d", heavy, counts[heavy])
+	}
+	if loads[heavy] != float64(counts[heavy]+19) {
+		t.Fatalf("Expected the load of %s to be %d, got %v", heavy, counts[heavy]+19, loads[heavy])
+	}
+
+	c.Add(testWeightedMember{name: "node4.olric", weight: 1})
+	total = 0
+	for _, load := range c.LoadDistribution() {
+		total += load
+	}
+	if total != 90 {
+		t.Fatalf("Expected the total load to be 90 after Add, got %v", total)
+	}
+}
+
+func TestWeightedConsistent_LocateKeyString(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 2},
+		testWeightedMember{name: "node3.olric", weight: 3},
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if got, want := c.FindPartitionIDString(key), c.FindPartitionID([]byte(key)); got != want {
+			t.Fatalf("Expected partition %d for %s, got %d", want, key, got)
+		}
+		if got, want := c.LocateKeyString(key), c.LocateKey([]byte(key)); got.String() != want.String() {
+			t.Fatalf("Expected owner %s for %s, got %s", want, key, got)
+		}
+	}
+
+	if owner := NewWeighted(nil, cfg).LocateKeyString("key"); owner != nil {
+		t.Fatalf("Expected nil owner on an empty ring, got %s", owner)
+	}
+}
+
+func TestWeightedConsistent_Equal(t *testing.T) {
+	var members []WeightedMember
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c1 := NewWeighted(members, cfg)
+	// Build the second ring from the members in another order, it must converge to the same view.
+	reversed := make([]WeightedMember, 0, len(members))
+	for i := len(members) - 1; i >= 0; i-- {
+		reversed = append(reversed, members[i])
+	}
+	c2 := NewWeighted(reversed, cfg)
+
+	if !c1.Equal(c2) || !c2.Equal(c1) {
+		t.Fatal("Expected the rings to be equal")
+	}
+	if !c1.Equal(c1) {
+		t.Fatal("Expected the ring to be equal to itself")
+	}
+	if c1.Equal(nil) {
+		t.Fatal("Expected the ring not to be equal to nil")
+	}
+
+	if err := c2.UpdateWeight("node1.olric", 5); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c1.Equal(c2) {
+		t.Fatal("Expected the rings to differ after a weight update")
+	}
+
+	c3 := c1.Clone()
+	if err := c3.SetPartitionCount(277); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c1.Equal(c3) {
+		t.Fatal("Expected the rings to differ after a partition count change")
+	}
+
+	c4 := c1.Clone()
+	c4.Remove("node7.olric")
+	if c1.Equal(c4) {
+		t.Fatal("Expected the rings to differ after a remove")
+	}
+	if !NewWeighted(nil, cfg).Equal(NewWeighted(nil, cfg)) {
+		t.Fatal("Expected empty rings to be equal")
+	}
+}
+
+func TestWeightedConsistent_PartitionHistory(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		TrackHistory:      3,
+	}
+	c := NewWeighted(nil, cfg)
+	if history := c.PartitionHistory(0); history != nil {
+		t.Fatalf("Expected no history on an empty ring, got %v", history)
+	}
+
+	expected := make(map[int][]string)
+	record := func() {
+		t.Helper()
+		for partID := 0; partID < cfg.PartitionCount; partID++ {
+			owner := c.GetPartitionOwner(partID)
+			if owner == nil {
+				continue
+			}
+			history := expected[partID]
+			if len(history) == 0 || history[len(history)-1] != owner.String() {
+				history = append(history, owner.String())
+			}
+			if len(history) > cfg.TrackHistory {
+				history = history[len(history)-cfg.TrackHistory:]
+			}
+			expected[partID] = history
+		}
+	}
+	check := func() {
+		t.Helper()
+		for partID := 0; partID < cfg.PartitionCount; partID++ {
+			if history := c.PartitionHistory(partID); !reflect.DeepEqual(history, expected[partID]) {
+				t.Fatalf("Expected history %v for partition %d, got %v", expected[partID], partID, history)
+			}
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		c.Add(testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+		record()
+		check()
+	}
+	for i := 0; i < 4; i++ {
+		c.Remove(fmt.Sprintf("node%d.olric", i))
+		record()
+		check()
+	}
+
+	var full bool
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		history := c.PartitionHistory(partID)
+		if len(history) > cfg.TrackHistory {
+			t.Fatalf("Expected at most %d owners for partition %d, got %v", cfg.TrackHistory, partID, history)
+		}
+		if len(history) == cfg.TrackHistory {
+			full = true
+		}
+		if owner := c.GetPartitionOwner(partID).String(); history[len(history)-1] != owner {
+			t.Fatalf("Expected %s to be the latest owner of partition %d, got %v", owner, partID, history)
+		}
+	}
+	if !full {
+		t.Fatal("Expected some partitions to reach the history limit")
+	}
+
+	cfg.TrackHistory = 0
+	c = NewWeighted([]WeightedMember{testWeightedMember{name: "node0.olric", weight: 1}}, cfg)
+	c.Add(testWeightedMember{name: "node1.olric", weight: 1})
+	if history := c.PartitionHistory(0); history != nil {
+		t.Fatalf("Expected no history when it's disabled, got %v", history)
+	}
+}
+
+type cappedTestMember struct {
+	testWeightedMember
+	maxLoad int
+}
+
+func (m cappedTestMember) MaxLoad() int {
+	return m.maxLoad
+}
+
+func TestWeightedConsistent_MaxLoad(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	newMembers := func(maxLoad int) []WeightedMember {
+		return []WeightedMember{
+			cappedTestMember{testWeightedMember{name: "node0.olric", weight: 4}, maxLoad},
+			testWeightedMember{name: "node1.olric", weight: 2},
+			testWeightedMember{name: "node2.olric", weight: 2},
+			testWeightedMember{name: "node3.olric", weight: 2},
+		}
+	}
+
+	// Without the cap node0.olric takes more than 20 partitions.
+	c := NewWeighted(newMembers(math.MaxInt32), cfg)
+	if load := c.LoadDistribution()["node0.olric"]; load <= 20 {
+		t.Fatalf("Expected the uncapped load of node0.olric to exceed 20, got %v", load)
+	}
+
+	checkCap := func(c *WeightedConsistent) {
+		t.Helper()
+		if load := c.LoadDistribution()["node0.olric"]; load > 20 {
+			t.Fatalf("Expected the load of node0.olric to be at most 20, got %v", load)
+		}
+		var total int
+		for partID := 0; partID < cfg.PartitionCount; partID++ {
+			if c.GetPartitionOwner(partID) != nil {
+				total++
+			}
+		}
+		if total != cfg.PartitionCount {
+			t.Fatalf("Expected %d owned partitions, got %d", cfg.PartitionCount, total)
+		}
+	}
+	c = NewWeighted(newMembers(20), cfg)
+	checkCap(c)
+	c.Add(testWeightedMember{name: "node4.olric", weight: 1})
+	checkCap(c)
+
+	cfg.StableTieBreak = true
+	checkCap(NewWeighted(newMembers(20), cfg))
+	cfg.StableTieBreak = false
+
+	cfg.DisableBoundedLoad = true
+	checkCap(NewWeighted(newMembers(20), cfg))
+	cfg.DisableBoundedLoad = false
+
+	// The other members can take at most 3 * 2 * ceil(71/10*1.25) = 54 partitions.
+	_, err := NewWeightedChecked(newMembers(20), cfg)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	_, err = NewWeightedChecked(newMembers(5), cfg)
+	if !errors.Is(err, ErrNotEnoughRoom) {
+		t.Fatalf("Expected ErrNotEnoughRoom, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_MemberPositions(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 3},
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	var total int
+	for _, member := range members {
+		positions := c.MemberPositions(member.String())
+		if len(positions) != cfg.ReplicationFactor*member.Weight() {
+			t.Fatalf("Expected %d positions for %s, got %d", cfg.ReplicationFactor*member.Weight(), member, len(positions))
+		}
+		if !sort.SliceIsSorted(positions, func(i, j int) bool { return positions[i] < positions[j] }) {
+			t.Fatalf("Expected the positions of %s to be sorted", member)
+		}
+		for i := range positions {
+			if !containsHash(positions, c.replicaHash(member.String(), i)) {
+				t.Fatalf("Expected the position of replica %d of %s", i, member)
+			}
+		}
+		total += len(positions)
+	}
+	if total != c.RingSize() {
+		t.Fatalf("Expected %d positions in total, got %d", c.RingSize(), total)
+	}
+
+	if positions := c.MemberPositions("unknown"); positions != nil {
+		t.Fatalf("Expected nil for an unknown member, got %v", positions)
+	}
+}
+
+func containsHash(hashes []uint64, h uint64) bool {
+	for _, x := range hashes {
+		if x == h {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWeightedConsistent_AddOrUpdate(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted([]WeightedMember{testWeightedMember{name: "node1.olric", weight: 1}}, cfg)
+
+	// Absent: the member is added.
+	if err := c.AddOrUpdate(testWeightedMember{name: "node2.olric", weight: 2}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !c.Has("node2.olric") || c.WeightDistribution()["node2.olric"] != 2 {
+		t.Fatal("Expected node2.olric to be added with weight 2")
+	}
+
+	// Present with the same weight: nothing changes.
+	var moved int
+	c.OnPartitionMoved(func(partID int, from, to string) {
+		moved++
+	})
+	before := c.PartitionTable()
+	if err := c.AddOrUpdate(testWeightedMember{name: "node2.olric", weight: 2}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if moved != 0 || !reflect.DeepEqual(before, c.PartitionTable()) {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+
+	// Present with another weight: the weight is updated.
+	if err := c.AddOrUpdate(testWeightedMember{name: "node2.olric", weight: 4}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if w := c.WeightDistribution()["node2.olric"]; w != 4 {
+		t.Fatalf("Expected weight 4, got %d", w)
+	}
+	if c.GetTotalWeight() != 5 || c.RingSize() != 5*cfg.ReplicationFactor {
+		t.Fatalf("Expected total weight 5 and %d positions, got %d and %d",
+			5*cfg.ReplicationFactor, c.GetTotalWeight(), c.RingSize())
+	}
+	if len(c.MemberPositions("node2.olric")) != 4*cfg.ReplicationFactor {
+		t.Fatalf("Expected %d positions for node2.olric, got %d", 4*cfg.ReplicationFactor, len(c.MemberPositions("node2.olric")))
+	}
+}
+
+type mutableCapTestMember struct {
+	testWeightedMember
+	maxLoad *int
+}
+
+func (m mutableCapTestMember) MaxLoad() int {
+	return *m.maxLoad
+}
+
+func TestWeightedConsistent_DryRunDistribute(t *testing.T) {
+	maxLoad := math.MaxInt32
+	members := []WeightedMember{
+		mutableCapTestMember{testWeightedMember{name: "node0.olric", weight: 4}, &maxLoad},
+		testWeightedMember{name: "node1.olric", weight: 1},
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.05,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+	if err := c.DryRunDistribute(); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	// node1.olric can take at most ceil(71/5*1.05) = 15 partitions, so the rest doesn't fit.
+	maxLoad = 10
+	before := c.PartitionTable()
+	err := c.DryRunDistribute()
+	if !errors.Is(err, ErrNotEnoughRoom) {
+		t.Fatalf("Expected ErrNotEnoughRoom, got %v", err)
+	}
+	var distErr *DistributionError
+	if !errors.As(err, &distErr) {
+		t.Fatalf("Expected a DistributionError, got %T", err)
+	}
+	if distErr.AverageLoad != 15 || distErr.TotalWeight != 5 || distErr.PartitionCount != 71 ||
+		distErr.RingSize != 100 || distErr.PartitionWeight != 1 {
+		t.Fatalf("Unexpected DistributionError: %+v", distErr)
+	}
+	if distErr.PartitionID < 0 || distErr.PartitionID >= cfg.PartitionCount {
+		t.Fatalf("Expected a valid partition ID, got %d", distErr.PartitionID)
+	}
+	if !reflect.DeepEqual(before, c.PartitionTable()) {
+		t.Fatal("Expected DryRunDistribute not to change the partition table")
+	}
+
+	if err := NewWeighted(nil, cfg).DryRunDistribute(); err != nil {
+		t.Fatalf("Expected nil on an empty ring, got: %v", err)
+	}
+}
+
+func TestWeightedConsistent_GetClosestNFiltered(t *testing.T) {
+	members := make([]WeightedMember, 0, 5)
+	for i := 0; i < 5; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%2 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		owner := c.LocateKey(key).String()
+		skipOwner := func(name string) bool { return name == owner }
+
+		res, err := c.GetClosestNFiltered(key, 2, skipOwner)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		closest, err := c.GetClosestN(key, 3)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if res[0].String() != closest[1].String() || res[1].String() != closest[2].String() {
+			t.Fatalf("Expected %s and %s for %s, got %s and %s", closest[1], closest[2], key, res[0], res[1])
+		}
+	}
+
+	key := []byte("key")
+	none := func(name string) bool { return false }
+	all, err := c.GetClosestNFiltered(key, len(members), none)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	closest, _ := c.GetClosestN(key, len(members))
+	if !reflect.DeepEqual(all, closest) {
+		t.Fatalf("Expected %v without a filter, got %v", closest, all)
+	}
+
+	maintenance := func(name string) bool { return name == "node1.olric" || name == "node3.olric" }
+	if _, err := c.GetClosestNFiltered(key, 4, maintenance); err != ErrInsufficientMemberCount {
+		t.Fatalf("Expected ErrInsufficientMemberCount, got %v", err)
+	}
+	if _, err := c.GetClosestNFiltered(key, 0, maintenance); err != ErrInvalidCount {
+		t.Fatalf("Expected ErrInvalidCount, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_LocateKeyHealthy(t *testing.T) {
+	members := make([]WeightedMember, 0, 5)
+	for i := 0; i < 5; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%2 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	healthy := func(name string) bool { return true }
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		owner := c.LocateKey(key).String()
+		if res := c.LocateKeyHealthy(key, healthy); res.String() != owner {
+			t.Fatalf("Expected %s for %s, got %s", owner, key, res)
+		}
+
+		// The primary is down, the next member on the ring takes over.
+		closest, err := c.GetClosestN(key, 3)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		primaryDown := func(name string) bool { return name != owner }
+		if res := c.LocateKeyHealthy(key, primaryDown); res.String() != closest[1].String() {
+			t.Fatalf("Expected %s for %s, got %s", closest[1], key, res)
+		}
+		twoDown := func(name string) bool { return name != owner && name != closest[1].String() }
+		if res := c.LocateKeyHealthy(key, twoDown); res.String() != closest[2].String() {
+			t.Fatalf("Expected %s for %s, got %s", closest[2], key, res)
+		}
+	}
+
+	unhealthy := func(name string) bool { return false }
+	if res := c.LocateKeyHealthy([]byte("key"), unhealthy); res != nil {
+		t.Fatalf("Expected nil, got %s", res)
+	}
+	empty := NewWeighted(nil, cfg)
+	if res := empty.LocateKeyHealthy([]byte("key"), healthy); res != nil {
+		t.Fatalf("Expected nil, got %s", res)
+	}
+}
+
+func TestWeightedConsistent_SecondaryOwner(t *testing.T) {
+	members := make([]WeightedMember, 0, 5)
+	for i := 0; i < 5; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%2 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		closest, err := c.GetClosestN(key, 2)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if secondary := c.SecondaryOwner(key); secondary.String() != closest[1].String() {
+			t.Fatalf("Expected %s for %s, got %s", closest[1], key, secondary)
+		}
+	}
+
+	single := NewWeighted(members[:1], cfg)
+	if secondary := single.SecondaryOwner([]byte("key")); secondary != nil {
+		t.Fatalf("Expected nil with a single member, got %s", secondary)
+	}
+	if secondary := NewWeighted(nil, cfg).SecondaryOwner([]byte("key")); secondary != nil {
+		t.Fatalf("Expected nil on an empty ring, got %s", secondary)
+	}
+
+	// The members of the owner's group are skipped like GetClosestN skips them.
+	cfg.AntiAffinityFunc = func(name string) string {
+		if name == "node1.olric" || name == "node2.olric" {
+			return "host1"
+		}
+		return name
+	}
+	c = NewWeighted(members, cfg)
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		closest, err := c.GetClosestN(key, 2)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if secondary := c.SecondaryOwner(key); secondary.String() != closest[1].String() {
+			t.Fatalf("Expected %s for %s, got %s", closest[1], key, secondary)
+		}
+	}
+	cfg.AntiAffinityFunc = func(string) string { return "host1" }
+	if secondary := NewWeighted(members, cfg).SecondaryOwner([]byte("key")); secondary != nil {
+		t.Fatalf("Expected nil with a single group, got %s", secondary)
+	}
+}
+
+func TestWeightedConsistent_GetClosestNAllMembers(t *testing.T) {
+	members := make([]WeightedMember, 0, 6)
+	for i := 0; i < 6; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		all, err := c.GetClosestN(key, len(members))
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if len(all) != len(members) || all[0].String() != c.LocateKey(key).String() {
+			t.Fatalf("Expected %d members starting with the owner, got %v", len(members), all)
+		}
+
+		// Every member is taken at its first replica after the owner's replica, so the distances
+		// from the owner's replica grow along the result.
+		partID := c.FindPartitionID(key)
+		origin := c.sortedSet[c.ownerIndex(partID, all[0].String())]
+		seen := make(map[string]struct{})
+		var last uint64
+		for j, member := range all {
+			if _, ok := seen[member.String()]; ok {
+				t.Fatalf("Expected no duplicates, got %s twice", member)
+			}
+			seen[member.String()] = struct{}{}
+
+			distance := uint64(math.MaxUint64)
+			for _, position := range c.MemberPositions(member.String()) {
+				if d := position - origin; d < distance {
+					distance = d
+				}
+			}
+			if j > 0 && distance <= last {
+				t.Fatalf("Expected %s after %s in ring order for %s", member, all[j-1], key)
+			}
+			last = distance
+		}
+
+		for count := 1; count < len(members); count++ {
+			closest, err := c.GetClosestN(key, count)
+			if err != nil {
+				t.Fatalf("Expected nil, got: %v", err)
+			}
+			if !reflect.DeepEqual(closest, all[:count]) {
+				t.Fatalf("Expected a prefix of %v, got %v", all, closest)
+			}
+		}
+	}
+}
+
+func TestWeightedConsistent_LocateKeyBalanced(t *testing.T) {
+	members := make([]WeightedMember, 0, 4)
+	for i := 0; i < 4; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		closest, err := c.GetClosestN(key, 3)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+
+		// Balanced loads keep the owner.
+		loads := map[string]int{"node0.olric": 10, "node1.olric": 10, "node2.olric": 10, "node3.olric": 10}
+		if owner := c.LocateKeyBalanced(key, loads); owner.String() != closest[0].String() {
+			t.Fatalf("Expected the owner %s for %s, got %s", closest[0], key, owner)
+		}
+
+		// The primary is full, the secondary is chosen.
+		loads[closest[0].String()] = 100
+		if owner := c.LocateKeyBalanced(key, loads); owner.String() != closest[1].String() {
+			t.Fatalf("Expected the secondary %s for %s, got %s", closest[1], key, owner)
+		}
+		loads[closest[1].String()] = 100
+		if owner := c.LocateKeyBalanced(key, loads); owner.String() != closest[2].String() {
+			t.Fatalf("Expected %s for %s, got %s", closest[2], key, owner)
+		}
+	}
+
+	if owner := c.LocateKeyBalanced([]byte("key"), nil); owner.String() != c.LocateKey([]byte("key")).String() {
+		t.Fatalf("Expected the owner without loads, got %s", owner)
+	}
+	if owner := NewWeighted(nil, cfg).LocateKeyBalanced([]byte("key"), nil); owner != nil {
+		t.Fatalf("Expected nil on an empty ring, got %s", owner)
+	}
+}
+
+func TestWeightedConsistent_AntiAffinityFunc(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		// a1 and a2 run on the same host.
+		AntiAffinityFunc: func(name string) string {
+			return name[:1]
+		},
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "a1", weight: 1},
+		testWeightedMember{name: "a2", weight: 1},
+		testWeightedMember{name: "b1", weight: 1},
+	}, cfg)
+
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		res, err := c.GetClosestN(key, 2)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if res[0].String() != c.LocateKey(key).String() {
+			t.Fatalf("Expected the owner %s first, got %s", c.LocateKey(key), res[0])
+		}
+		if res[0].String()[:1] == res[1].String()[:1] {
+			t.Fatalf("Expected members of different groups for %s, got %s and %s", key, res[0], res[1])
+		}
+		next, err := c.GetClosestNExcludingOwner(key, 1)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if next[0].String() != res[1].String() {
+			t.Fatalf("Expected %s, got %s", res[1], next[0])
+		}
+	}
+	if _, err := c.GetClosestN([]byte("key"), 3); err != ErrInsufficientMemberCount {
+		t.Fatalf("Expected ErrInsufficientMemberCount with 2 groups, got %v", err)
+	}
+
+	sets, err := c.ReplicaSets(2)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	for partID, set := range sets {
+		if set[0].String()[:1] == set[1].String()[:1] {
+			t.Fatalf("Expected members of different groups for partition %d, got %s and %s", partID, set[0], set[1])
+		}
+	}
+}
+
+// cancelingHasher calls cancel after the given number of hashes.
+type cancelingHasher struct {
+	hashes int
+	cancel context.CancelFunc
+}
+
+func (h *cancelingHasher) Sum64(data []byte) uint64 {
+	h.hashes--
+	if h.hashes == 0 {
+		h.cancel()
+	}
+	return testWeightedHasher{}.Sum64(data)
+}
+
+func TestWeightedConsistent_Context(t *testing.T) {
+	members := make([]WeightedMember, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+
+	// Cancel while the members are placed on the ring.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hasher := &cancelingHasher{hashes: 5000, cancel: cancel}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            hasher,
+	}
+	c, err := NewWeightedContext(ctx, members, cfg)
+	if err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if c != nil {
+		t.Fatal("Expected nil ring on error")
+	}
+	if hasher.hashes > 0 || hasher.hashes < -contextCheckInterval*cfg.ReplicationFactor {
+		t.Fatalf("Expected the build to stop soon after the cancellation, %d hashes left", hasher.hashes)
+	}
+
+	hasher.hashes = -1
+	c, err = NewWeightedContext(context.Background(), members, cfg)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	// Cancel while the replicas of the added member are placed, before the partitions are redistributed.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	hasher.hashes, hasher.cancel = 10, cancel
+	before := c.Clone()
+	if err := c.AddContext(ctx, testWeightedMember{name: "node1000.olric", weight: 1}); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if !c.Equal(before) || c.Has("node1000.olric") || c.RingSize() != before.RingSize() {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+	if err := c.AddContext(ctx, testWeightedMember{name: "node1000.olric", weight: 1}); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if err := c.AddContext(context.Background(), testWeightedMember{name: "node1000.olric", weight: 1}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+}
+
+func TestWeightedConsistent_ConcurrentRemoveSameMember(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Remove("node1.olric")
+			if i%2 == 0 {
+				c.RemoveMany([]string{"node2.olric", "node2.olric"})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var expected int
+	for name, weight := range c.WeightDistribution() {
+		if name == "node1.olric" || name == "node2.olric" {
+			t.Fatalf("Expected %s to be removed", name)
+		}
+		expected += weight
+	}
+	if total := c.GetTotalWeight(); total != expected || total != 10 {
+		t.Fatalf("Expected total weight %d, got %d", expected, total)
+	}
+	if c.RingSize() != expected*cfg.ReplicationFactor {
+		t.Fatalf("Expected %d positions, got %d", expected*cfg.ReplicationFactor, c.RingSize())
+	}
+
+	// Deleting the weight of a removed member again is a no-op.
+	c.mu.Lock()
+	c.delWeight("node1.olric")
+	c.mu.Unlock()
+	if total := c.GetTotalWeight(); total != expected {
+		t.Fatalf("Expected total weight %d, got %d", expected, total)
+	}
+}
+
+func TestWeightedConsistent_Version(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "node1", weight: 1},
+		testWeightedMember{name: "node2", weight: 2},
+	}, cfg)
+
+	version := c.Version()
+	c.Add(testWeightedMember{name: "node1", weight: 1})
+	c.Remove("node3")
+	if c.Version() != version {
+		t.Fatalf("Expected version %d after no-op changes, got %d", version, c.Version())
+	}
+
+	steps := []func(){
+		func() { c.Add(testWeightedMember{name: "node3", weight: 1}) },
+		func() {
+			if err := c.UpdateWeight("node3", 3); err != nil {
+				t.Fatalf("Expected nil, got: %v", err)
+			}
+		},
+		func() {
+			if err := c.SetPartitionCount(113); err != nil {
+				t.Fatalf("Expected nil, got: %v", err)
+			}
+		},
+		func() { c.Remove("node3") },
+	}
+	for i, step := range steps {
+		step()
+		if c.Version() <= version {
+			t.Fatalf("Expected version to be incremented by step %d, got %d", i, c.Version())
+		}
+		version = c.Version()
+	}
+}
+
+func TestWeightedConsistent_Fingerprint(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c1 := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "node1", weight: 1},
+		testWeightedMember{name: "node2", weight: 2},
+	}, cfg)
+	c2 := NewWeighted([]WeightedMember{testWeightedMember{name: "node2", weight: 2}}, cfg)
+	c2.Add(testWeightedMember{name: "node1", weight: 1})
+	if c1.Fingerprint() != c2.Fingerprint() {
+		t.Fatalf("Expected equal fingerprints, got %x and %x", c1.Fingerprint(), c2.Fingerprint())
+	}
+
+	if err := c2.UpdateWeight("node1", 2); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c1.Fingerprint() == c2.Fingerprint() {
+		t.Fatal("Expected the fingerprints to differ after a weight change")
+	}
+}
+
+type hashKeyTestMember struct {
+	testWeightedMember
+	id string
+}
+
+func (m hashKeyTestMember) HashKey() []byte {
+	return []byte(m.id)
+}
+
+func TestWeightedConsistent_HashKey(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	others := []WeightedMember{
+		testWeightedMember{name: "node1", weight: 1},
+		testWeightedMember{name: "node2", weight: 1},
+	}
+
+	// Two members with the same display name are placed by their hash keys.
+	web1 := hashKeyTestMember{testWeightedMember{name: "web", weight: 2}, "0b5c7e3a"}
+	web2 := hashKeyTestMember{testWeightedMember{name: "web", weight: 2}, "9f1d24c6"}
+	c1 := NewWeighted(append([]WeightedMember{web1}, others...), cfg)
+	c2 := NewWeighted(append([]WeightedMember{web2}, others...), cfg)
+	if reflect.DeepEqual(c1.MemberPositions("web"), c2.MemberPositions("web")) {
+		t.Fatal("Expected the members to be placed by their hash keys")
+	}
+
+	// Renaming a member doesn't move its replicas.
+	renamed := hashKeyTestMember{testWeightedMember{name: "web-renamed", weight: 2}, "0b5c7e3a"}
+	c3 := NewWeighted(append([]WeightedMember{renamed}, others...), cfg)
+	if !reflect.DeepEqual(c1.MemberPositions("web"), c3.MemberPositions("web-renamed")) {
+		t.Fatal("Expected the renamed member to keep its positions")
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		owner1, owner3 := c1.GetPartitionOwner(partID).String(), c3.GetPartitionOwner(partID).String()
+		if owner1 != owner3 && !(owner1 == "web" && owner3 == "web-renamed") {
+			t.Fatalf("Expected partition %d to keep its owner, got %s and %s", partID, owner1, owner3)
+		}
+	}
+
+	// The name is still the identity of the member in the ring.
+	c1.Add(web2)
+	if c1.MembersCount() != 3 || !reflect.DeepEqual(c1.MemberPositions("web"), c3.MemberPositions("web-renamed")) {
+		t.Fatal("Expected a member with the same name to be ignored")
+	}
+	c1.Remove("web")
+	if c1.RingSize() != 2*cfg.ReplicationFactor {
+		t.Fatalf("Expected %d positions, got %d", 2*cfg.ReplicationFactor, c1.RingSize())
+	}
+
+	// The members cannot share a hash key, neither with another hash key nor with a name.
+	if err := c3.AddChecked(hashKeyTestMember{testWeightedMember{name: "api", weight: 1}, "0b5c7e3a"}); err != ErrHashKeyConflict {
+		t.Fatalf("Expected ErrHashKeyConflict, got %v", err)
+	}
+	if err := c3.AddChecked(hashKeyTestMember{testWeightedMember{name: "api", weight: 1}, "node1"}); err != ErrHashKeyConflict {
+		t.Fatalf("Expected ErrHashKeyConflict, got %v", err)
+	}
+	if c3.MembersCount() != 3 {
+		t.Fatalf("Expected 3 members, got %d", c3.MembersCount())
+	}
+	_, err := NewWeightedChecked([]WeightedMember{web1, renamed}, cfg)
+	if err != ErrHashKeyConflict {
+		t.Fatalf("Expected ErrHashKeyConflict, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_GetClosestNWithDistance(t *testing.T) {
+	members := []WeightedMember{}
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		replicas, err := c.GetClosestNWithDistance(key, 4)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if len(replicas) != 4 {
+			t.Fatalf("Expected 4 replicas, got %d", len(replicas))
+		}
+		seen := make(map[string]struct{})
+		for j, replica := range replicas {
+			if _, ok := seen[replica.Member.String()]; ok {
+				t.Fatalf("Duplicate member %s for %s", replica.Member, key)
+			}
+			seen[replica.Member.String()] = struct{}{}
+			if j > 0 && replica.Distance < replicas[j-1].Distance {
+				t.Fatalf("Expected non-decreasing distances for %s, got %d after %d",
+					key, replica.Distance, replicas[j-1].Distance)
+			}
+		}
+	}
+
+	if _, err := c.GetClosestNWithDistance([]byte("key"), 9); err != ErrInsufficientMemberCount {
+		t.Fatalf("Expected ErrInsufficientMemberCount, got %v", err)
+	}
+	if _, err := c.GetClosestNWithDistance([]byte("key"), 0); err != ErrInvalidCount {
+		t.Fatalf("Expected ErrInvalidCount, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_Counters(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	var moved uint64
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "node1", weight: 1},
+		testWeightedMember{name: "node2", weight: 2},
+	}, cfg)
+	c.OnPartitionMoved(func(partID int, from, to string) {
+		moved++
+	})
+	if c.Adds() != 0 || c.Removes() != 0 || c.Redistributions() != 1 {
+		t.Fatalf("Expected 0 adds, 0 removes and 1 redistribution, got %d, %d and %d",
+			c.Adds(), c.Removes(), c.Redistributions())
+	}
+	initial := c.PartitionsMoved()
+	if initial != uint64(cfg.PartitionCount) {
+		t.Fatalf("Expected %d partitions moved, got %d", cfg.PartitionCount, initial)
+	}
+
+	c.Add(testWeightedMember{name: "node3", weight: 1})
+	c.Add(testWeightedMember{name: "node3", weight: 1})
+	c.AddMany([]WeightedMember{
+		testWeightedMember{name: "node4", weight: 1},
+		testWeightedMember{name: "node5", weight: 1},
+	})
+	if err := c.UpdateWeight("node1", 3); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	c.Remove("node2")
+	c.Remove("node2")
+	c.RemoveMany([]string{"node3", "node4", "unknown"})
+
+	if c.Adds() != 3 {
+		t.Fatalf("Expected 3 adds, got %d", c.Adds())
+	}
+	if c.Removes() != 3 {
+		t.Fatalf("Expected 3 removes, got %d", c.Removes())
+	}
+	if c.Redistributions() != 6 {
+		t.Fatalf("Expected 6 redistributions, got %d", c.Redistributions())
+	}
+	if c.PartitionsMoved() != initial+moved {
+		t.Fatalf("Expected %d partitions moved, got %d", initial+moved, c.PartitionsMoved())
+	}
+}
+
+func TestWeightedConsistent_ParentPartition(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "node1", weight: 1},
+		testWeightedMember{name: "node2", weight: 2},
+	}, cfg)
+	if c.ParentPartition(5) != 5 {
+		t.Fatalf("Expected partition 5 to be its own parent, got %d", c.ParentPartition(5))
+	}
+
+	before := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = c.FindPartitionIDString(key)
+	}
+	if err := c.GrowPartitionCount(2); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.PartitionCount() != 142 {
+		t.Fatalf("Expected 142 partitions, got %d", c.PartitionCount())
+	}
+	for partID := 0; partID < 142; partID++ {
+		if parent := c.ParentPartition(partID); parent != partID%71 {
+			t.Fatalf("Expected parent %d of partition %d, got %d", partID%71, partID, parent)
+		}
+	}
+	for key, partID := range before {
+		if parent := c.ParentPartition(c.FindPartitionIDString(key)); parent != partID {
+			t.Fatalf("Expected %s to descend from partition %d, got %d", key, partID, parent)
+		}
+	}
+	if c.ParentPartition(142) != -1 {
+		t.Fatalf("Expected -1 out of range, got %d", c.ParentPartition(142))
+	}
+
+	// Only the multiples preserve the affinity.
+	if err := c.SetPartitionCount(211); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.ParentPartition(0) != -1 {
+		t.Fatalf("Expected no parent, got %d", c.ParentPartition(0))
+	}
+	if err := c.GrowPartitionCount(0); err != ErrInvalidPartitionCount {
+		t.Fatalf("Expected ErrInvalidPartitionCount, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_SetDraining(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "node1", weight: 4},
+		testWeightedMember{name: "node2", weight: 2},
+		testWeightedMember{name: "node3", weight: 2},
+	}, cfg)
+
+	owned := len(c.OwnedPartitions("node1"))
+	if err := c.SetDraining("node1", true); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !c.IsDraining("node1") {
+		t.Fatal("Expected node1 to be draining")
+	}
+	if drained := len(c.OwnedPartitions("node1")); drained >= owned {
+		t.Fatalf("Expected node1 to own fewer than %d partitions, got %d", owned, drained)
+	}
+	if c.WeightDistribution()["node1"] != 1 || c.ReplicaCount("node1") != cfg.ReplicationFactor {
+		t.Fatalf("Expected weight 1 while draining, got %d", c.WeightDistribution()["node1"])
+	}
+
+	// A draining member is still reachable.
+	replicas, err := c.GetClosestN([]byte("key"), 3)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	var found bool
+	for _, replica := range replicas {
+		found = found || replica.String() == "node1"
+	}
+	if !found {
+		t.Fatal("Expected node1 to be returned by GetClosestN")
+	}
+
+	// The weight is restored when the member stops draining, including the updates while draining.
+	if err := c.UpdateWeight("node1", 5); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.WeightDistribution()["node1"] != 1 {
+		t.Fatalf("Expected weight 1 while draining, got %d", c.WeightDistribution()["node1"])
+	}
+	if err := c.SetDraining("node1", false); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.IsDraining("node1") || c.WeightDistribution()["node1"] != 5 {
+		t.Fatalf("Expected weight 5 after draining, got %d", c.WeightDistribution()["node1"])
+	}
+	if restored := len(c.OwnedPartitions("node1")); restored <= owned {
+		t.Fatalf("Expected node1 to own more than %d partitions, got %d", owned, restored)
+	}
+
+	if err := c.SetDraining("unknown", true); err != ErrMemberNotFound {
+		t.Fatalf("Expected ErrMemberNotFound, got %v", err)
+	}
+	if err := c.SetDraining("node2", true); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	c.Remove("node2")
+	if c.IsDraining("node2") {
+		t.Fatal("Expected a removed member not to be draining")
+	}
+}
+
+func TestWeightedConsistent_EmptyName(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted([]WeightedMember{testWeightedMember{name: "node1", weight: 2}}, cfg)
+
+	if err := c.AddChecked(testWeightedMember{name: "", weight: 3}); err != ErrEmptyMemberName {
+		t.Fatalf("Expected ErrEmptyMemberName, got %v", err)
+	}
+	if c.GetTotalWeight() != 2 || c.MembersCount() != 1 || c.RingSize() != 2*cfg.ReplicationFactor {
+		t.Fatalf("Expected the ring to be unchanged, got total weight %d and %d members",
+			c.GetTotalWeight(), c.MembersCount())
+	}
+	if _, err := c.Reconcile([]WeightedMember{testWeightedMember{name: "", weight: 1}}); err != ErrEmptyMemberName {
+		t.Fatalf("Expected ErrEmptyMemberName, got %v", err)
+	}
+	if _, err := NewWeightedChecked([]WeightedMember{testWeightedMember{name: "", weight: 1}}, cfg); err != ErrEmptyMemberName {
+		t.Fatalf("Expected ErrEmptyMemberName, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_ReplicaSets(t *testing.T) {
+	members := []WeightedMember{}
+	for i := 0; i < 6; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	sets, err := c.ReplicaSets(3)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if len(sets) != cfg.PartitionCount {
+		t.Fatalf("Expected %d replica sets, got %d", cfg.PartitionCount, len(sets))
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		expected, err := c.GetClosestNForPartition(partID, 3)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if !reflect.DeepEqual(sets[partID], expected) {
+			t.Fatalf("Expected %v for partition %d, got %v", expected, partID, sets[partID])
+		}
+	}
+
+	if _, err := c.ReplicaSets(7); err != ErrInsufficientMemberCount {
+		t.Fatalf("Expected ErrInsufficientMemberCount, got %v", err)
+	}
+	if _, err := c.ReplicaSets(0); err != ErrInvalidCount {
+		t.Fatalf("Expected ErrInvalidCount, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_ReplicaSetSize(t *testing.T) {
+	members := []WeightedMember{}
+	for i := 0; i < 6; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		ReplicaSetSize:    3,
+	}
+	c := NewWeighted(members, cfg)
+
+	check := func() {
+		var total float64
+		avgLoad := c.AverageLoad()
+		weights := c.WeightDistribution()
+		for name, load := range c.LoadDistribution() {
+			total += load
+			if load > avgLoad*float64(weights[name]) {
+				t.Fatalf("%s exceeds the expected load %v: %v", name, avgLoad*float64(weights[name]), load)
+			}
+		}
+		if total != float64(3*cfg.PartitionCount) {
+			t.Fatalf("Expected the loads to sum up to %d, got %v", 3*cfg.PartitionCount, total)
+		}
+		for partID := 0; partID < cfg.PartitionCount; partID++ {
+			set := c.GetReplicaSet(partID)
+			if len(set) != 3 {
+				t.Fatalf("Expected 3 members for partition %d, got %d", partID, len(set))
+			}
+			if set[0].String() != c.GetPartitionOwner(partID).String() {
+				t.Fatalf("Expected the owner of partition %d first, got %s", partID, set[0])
+			}
+			if set[0].String() == set[1].String() || set[0].String() == set[2].String() || set[1].String() == set[2].String() {
+				t.Fatalf("Expected distinct members for partition %d, got %v", partID, set)
+			}
+		}
+	}
+	check()
+
+	c.Add(testWeightedMember{name: "node6.olric", weight: 2})
+	check()
+	c.Remove("node0.olric")
+	check()
+
+	// The replica set size is capped by the number of members.
+	c = NewWeighted(members[:2], cfg)
+	if set := c.GetReplicaSet(0); len(set) != 2 {
+		t.Fatalf("Expected 2 members, got %d", len(set))
+	}
+}
+
+func TestWeightedConsistent_EmptyMemberSlice(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c, err := NewWeightedChecked([]WeightedMember{}, cfg)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.MembersCount() != 0 || c.LocateKey([]byte("key")) != nil {
+		t.Fatal("Expected an empty ring")
+	}
+
+	// It doesn't panic and the ring can grow from there.
+	c = NewWeighted([]WeightedMember{}, cfg)
+	c.Add(testWeightedMember{name: "node1.olric", weight: 1})
+	if owner := c.LocateKey([]byte("key")); owner == nil || owner.String() != "node1.olric" {
+		t.Fatalf("Expected node1.olric, got %v", owner)
+	}
+}
+
+func TestWeightedConsistent_EmptyRing(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(nil, cfg)
+	key := []byte("key")
+
+	if c.LocateKey(key) != nil || c.LocateKeyString("key") != nil || c.GetPartitionOwner(0) != nil {
+		t.Fatal("Expected no owner on an empty ring")
+	}
+	if owners := c.LocateKeys([][]byte{key}); len(owners) != 1 || owners[0] != nil {
+		t.Fatalf("Expected a nil owner, got %v", owners)
+	}
+	if id := c.FindPartitionID(key); id < 0 || id >= cfg.PartitionCount {
+		t.Fatalf("Expected a partition ID in range, got %d", id)
+	}
+	if c.GetReplicaSet(0) != nil || c.MemberPositions("node1") != nil {
+		t.Fatal("Expected nil on an empty ring")
+	}
+	if len(c.GetMembers()) != 0 || c.MembersCount() != 0 || c.RingSize() != 0 || c.GetTotalWeight() != 0 {
+		t.Fatal("Expected no members on an empty ring")
+	}
+	if len(c.LoadDistribution()) != 0 || len(c.WeightDistribution()) != 0 || len(c.PartitionTable()) != 0 ||
+		len(c.OwnedPartitions("node1")) != 0 || len(c.OwnerRanges()) != 0 || len(c.OverloadedMembers()) != 0 {
+		t.Fatal("Expected empty collections on an empty ring")
+	}
+	if c.AverageLoad() != 0 || c.LoadImbalance() != 0 || c.LoadStdDev() != 0 || c.ExpectedLoad("node1") != 0 {
+		t.Fatal("Expected zero load statistics on an empty ring")
+	}
+	c.EachPartition(func(partID int, owner WeightedMember) bool {
+		t.Fatalf("Expected no owned partitions, got %d", partID)
+		return false
+	})
+	if c.Has("node1") || c.ReplicaCount("node1") != 0 || c.IsDraining("node1") {
+		t.Fatal("Expected no member on an empty ring")
+	}
+	if err := c.DryRunDistribute(); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if err := c.UpdateWeight("node1", 2); err != ErrMemberNotFound {
+		t.Fatalf("Expected ErrMemberNotFound, got %v", err)
+	}
+	if err := c.SetDraining("node1", true); err != ErrMemberNotFound {
+		t.Fatalf("Expected ErrMemberNotFound, got %v", err)
+	}
+
+	closest := map[string]func(count int) error{
+		"GetClosestN": func(count int) error {
+			_, err := c.GetClosestN(key, count)
+			return err
+		},
+		"GetClosestNForPartition": func(count int) error {
+			_, err := c.GetClosestNForPartition(0, count)
+			return err
+		},
+		"GetClosestNExcludingOwner": func(count int) error {
+			_, err := c.GetClosestNExcludingOwner(key, count)
+			return err
+		},
+		"GetClosestNFiltered": func(count int) error {
+			_, err := c.GetClosestNFiltered(key, count, func(string) bool { return false })
+			return err
+		},
+		"GetClosestNDistinctZones": func(count int) error {
+			_, err := c.GetClosestNDistinctZones(key, count)
+			return err
+		},
+		"GetClosestNWithDistance": func(count int) error {
+			_, err := c.GetClosestNWithDistance(key, count)
+			return err
+		},
+		"ReplicaSets": func(count int) error {
+			_, err := c.ReplicaSets(count)
+			return err
+		},
+	}
+	for name, fn := range closest {
+		if err := fn(1); err != ErrEmptyRing {
+			t.Fatalf("Expected ErrEmptyRing from %s, got %v", name, err)
+		}
+		if err := fn(0); err != ErrInvalidCount {
+			t.Fatalf("Expected ErrInvalidCount from %s, got %v", name, err)
+		}
+	}
+	if !errors.Is(ErrEmptyRing, ErrInsufficientMemberCount) {
+		t.Fatal("Expected ErrEmptyRing to wrap ErrInsufficientMemberCount")
+	}
+
+	// Removing the last member brings the ring back to the same state.
+	c.Add(testWeightedMember{name: "node1", weight: 1})
+	c.Remove("node1")
+	if c.LocateKey(key) != nil {
+		t.Fatal("Expected no owner after removing the last member")
+	}
+	if _, err := c.GetClosestN(key, 1); err != ErrEmptyRing {
+		t.Fatalf("Expected ErrEmptyRing, got %v", err)
+	}
+
+	// Removing every member, one by one or at once, leaves no loads behind.
+	members := []WeightedMember{
+		testWeightedMember{name: "node1", weight: 1},
+		testWeightedMember{name: "node2", weight: 2},
+	}
+	removeAll := map[string]func(c *WeightedConsistent){
+		"Remove": func(c *WeightedConsistent) {
+			c.Remove("node1")
+			c.Remove("node2")
+		},
+		"RemoveMany": func(c *WeightedConsistent) {
+			c.RemoveMany([]string{"node1", "node2"})
+		},
+		"Reconcile": func(c *WeightedConsistent) {
+			if _, err := c.Reconcile(nil); err != nil {
+				t.Fatalf("Expected nil, got: %v", err)
+			}
+		},
+		"Swap": func(c *WeightedConsistent) {
+			if err := c.Swap(nil); err != nil {
+				t.Fatalf("Expected nil, got: %v", err)
+			}
+		},
+	}
+	for name, fn := range removeAll {
+		c := NewWeighted(members, cfg)
+		fn(c)
+		if c.MembersCount() != 0 || len(c.LoadDistribution()) != 0 || len(c.PartitionTable()) != 0 {
+			t.Fatalf("Expected an empty ring after %s, got loads %v", name, c.LoadDistribution())
+		}
+		if c.AverageLoad() != 0 || c.LoadStdDev() != 0 || len(c.OverloadedMembers()) != 0 {
+			t.Fatalf("Expected zero load statistics after %s", name)
+		}
+	}
+}
+
+func TestWeightedConsistent_MemberConflict(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(nil, cfg)
+
+	if err := c.AddChecked(testWeightedMember{name: "x", weight: 2}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if err := c.AddChecked(testWeightedMember{name: "x", weight: 2}); err != nil {
+		t.Fatalf("Expected adding the same member again to do nothing, got %v", err)
+	}
+	if err := c.AddChecked(testWeightedMember{name: "x", weight: 5}); err != ErrMemberConflict {
+		t.Fatalf("Expected ErrMemberConflict, got %v", err)
+	}
+	if c.WeightDistribution()["x"] != 2 || c.RingSize() != 2*cfg.ReplicationFactor {
+		t.Fatalf("Expected x to keep weight 2, got %d", c.WeightDistribution()["x"])
+	}
+
+	_, err := NewWeightedChecked([]WeightedMember{
+		testWeightedMember{name: "x", weight: 2},
+		testWeightedMember{name: "x", weight: 5},
+	}, cfg)
+	if err != ErrMemberConflict {
+		t.Fatalf("Expected ErrMemberConflict, got %v", err)
+	}
+	c, err = NewWeightedChecked([]WeightedMember{
+		testWeightedMember{name: "x", weight: 2},
+		testWeightedMember{name: "x", weight: 2},
+	}, cfg)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.RingSize() != 2*cfg.ReplicationFactor {
+		t.Fatalf("Expected the repeated member to be added once, got %d positions", c.RingSize())
+	}
+}
+
+func TestWeightedConsistent_HasherAndConfig(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted([]WeightedMember{testWeightedMember{name: "node1.olric", weight: 1}}, cfg)
+
+	if c.Hasher() != (testWeightedHasher{}) {
+		t.Fatalf("Expected testWeightedHasher, got %T", c.Hasher())
+	}
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if partID := int(c.Hasher().Sum64(key) % 71); partID != c.FindPartitionID(key) {
+			t.Fatalf("Expected partition %d for %s, got %d", c.FindPartitionID(key), key, partID)
+		}
+	}
+
+	got := c.Config()
+	if got.PartitionCount != 71 || got.ReplicationFactor != 20 || got.Load != DefaultLoad {
+		t.Fatalf("Expected the config with the default load, got %+v", got)
+	}
+	if err := c.SetPartitionCount(101); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.Config().PartitionCount != 101 {
+		t.Fatalf("Expected 101 partitions, got %d", c.Config().PartitionCount)
+	}
+
+	c = NewWeighted(nil, WeightedConfig{PartitionCount: 71})
+	if c.Hasher() != (DefaultHasher{}) || c.Config().Hasher != (DefaultHasher{}) {
+		t.Fatalf("Expected DefaultHasher, got %T", c.Hasher())
+	}
+}
+
+func TestWeightedConsistent_Deterministic(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	expected := NewWeighted(members, cfg)
+	names := func(c *WeightedConsistent) []string {
+		var res []string
+		for _, member := range c.GetMembers() {
+			res = append(res, member.String())
+		}
+		return res
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		// The order of the members doesn't matter either, as long as their replicas don't collide.
+		shuffled := append([]WeightedMember(nil), members...)
+		r.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		c := NewWeighted(shuffled, cfg)
+
+		if !reflect.DeepEqual(c.PartitionTable(), expected.PartitionTable()) {
+			t.Fatalf("Expected identical partition tables in run %d", i)
+		}
+		if !reflect.DeepEqual(names(c), names(expected)) {
+			t.Fatalf("Expected members %v in run %d, got %v", names(expected), i, names(c))
+		}
+		if c.LoadStdDev() != expected.LoadStdDev() {
+			t.Fatalf("Expected load std dev %v in run %d, got %v", expected.LoadStdDev(), i, c.LoadStdDev())
+		}
+		closest, err := c.GetClosestN([]byte("key"), 4)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		want, _ := expected.GetClosestN([]byte("key"), 4)
+		if !reflect.DeepEqual(closest, want) {
+			t.Fatalf("Expected %v in run %d, got %v", want, i, closest)
+		}
+	}
+	if !sort.StringsAreSorted(names(expected)) {
+		t.Fatalf("Expected the members to be sorted by name, got %v", names(expected))
+	}
+}
+
+func TestWeightedConsistent_OnDistributeFailure(t *testing.T) {
+	const heavyPartition = 0
+	var failed []int
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		// The heavy partition doesn't fit any member.
+		PartitionWeightFunc: func(partID int) float64 {
+			if partID == heavyPartition {
+				return 200
+			}
+			return 1
+		},
+		OnDistributeFailure: func(partID int) {
+			failed = append(failed, partID)
+		},
+	}
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 1},
+	}
+
+	// It doesn't panic.
+	c := NewWeighted(members, cfg)
+	if !reflect.DeepEqual(failed, []int{heavyPartition}) {
+		t.Fatalf("Expected the callback for partition %d, got %v", heavyPartition, failed)
+	}
+	if owner := c.GetPartitionOwner(heavyPartition); owner != nil {
+		t.Fatalf("Expected no owner, got %s", owner)
+	}
+	for partID := 1; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID) == nil {
+			t.Fatalf("Expected an owner of partition %d", partID)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if c.FindPartitionID(key) == heavyPartition {
+			if owner := c.LocateKey(key); owner != nil {
+				t.Fatalf("Expected no owner of %s, got %s", key, owner)
+			}
+			break
+		}
+	}
+	if err := c.DryRunDistribute(); !errors.Is(err, ErrNotEnoughRoom) {
+		t.Fatalf("Expected ErrNotEnoughRoom, got %v", err)
+	}
+
+	// The partition is retried by the later changes of the ring.
+	failed = nil
+	c.Add(testWeightedMember{name: "node3.olric", weight: 1})
+	if !reflect.DeepEqual(failed, []int{heavyPartition}) {
+		t.Fatalf("Expected the callback for partition %d, got %v", heavyPartition, failed)
+	}
+	c.Remove("node1.olric")
+	if len(failed) != 2 || c.GetPartitionOwner(heavyPartition) != nil {
+		t.Fatalf("Expected the callback again, got %v", failed)
+	}
+}
+
+func TestWeightedConsistent_PartitionKeyFunc(t *testing.T) {
+	members := make([]WeightedMember, 0, 5)
+	for i := 0; i < 5; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%2 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	def := NewWeighted(members, cfg)
+
+	// The default is the little-endian partition ID.
+	cfg.PartitionKeyFunc = func(partID int) []byte {
+		bs := make([]byte, 8)
+		binary.LittleEndian.PutUint64(bs, uint64(partID))
+		return bs
+	}
+	if c := NewWeighted(members, cfg); !reflect.DeepEqual(c.PartitionTable(), def.PartitionTable()) {
+		t.Fatal("Expected the default partition table")
+	}
+
+	cfg.PartitionKeyFunc = func(partID int) []byte {
+		bs := make([]byte, 8)
+		binary.BigEndian.PutUint64(bs, uint64(partID))
+		return bs
+	}
+	c := NewWeighted(members, cfg)
+	if reflect.DeepEqual(c.PartitionTable(), def.PartitionTable()) {
+		t.Fatal("Expected the partition key to change the partition table")
+	}
+	if !reflect.DeepEqual(c.PartitionTable(), NewWeighted(members, cfg).PartitionTable()) {
+		t.Fatal("Expected identical partition tables with the same partition key")
+	}
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, 42)
+	if c.partitionHashes[42] != (testWeightedHasher{}).Sum64(bs) {
+		t.Fatalf("Expected partition 42 to be placed at the hash of its key, got %d", c.partitionHashes[42])
+	}
+}
+
+func TestWeightedConsistent_SampleKeys(t *testing.T) {
+	members := make([]WeightedMember, 0, 5)
+	for i := 0; i < 5; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%2 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	for _, member := range members {
+		keys := c.SampleKeys(member.String(), 50)
+		if len(keys) != 50 {
+			t.Fatalf("Expected 50 keys of %s, got %d", member, len(keys))
+		}
+		seen := make(map[string]struct{})
+		for _, key := range keys {
+			if owner := c.LocateKey(key); owner.String() != member.String() {
+				t.Fatalf("Expected %s to own %s, got %s", member, key, owner)
+			}
+			if _, ok := seen[string(key)]; ok {
+				t.Fatalf("Expected distinct keys, got %s twice", key)
+			}
+			seen[string(key)] = struct{}{}
+		}
+		if !reflect.DeepEqual(keys, c.SampleKeys(member.String(), 50)) {
+			t.Fatalf("Expected the same keys of %s", member)
+		}
+	}
+
+	if keys := c.SampleKeys("unknown", 10); keys != nil {
+		t.Fatalf("Expected nil for an unknown member, got %d keys", len(keys))
+	}
+	if keys := c.SampleKeys("node1.olric", 0); keys != nil {
+		t.Fatalf("Expected nil for 0 keys, got %d keys", len(keys))
+	}
+}
+
+func TestWeightedConsistent_LocateKeyWithSalt(t *testing.T) {
+	members := make([]WeightedMember, 0, 5)
+	for i := 0; i < 5; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%2 + 1})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	var differ int
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		tenant1 := c.LocateKeyWithSalt([]byte("tenant1"), key)
+		tenant2 := c.LocateKeyWithSalt([]byte("tenant2"), key)
+		if expected := c.LocateKey([]byte("tenant1" + string(key))); tenant1.String() != expected.String() {
+			t.Fatalf("Expected %s for %s, got %s", expected, key, tenant1)
+		}
+		if tenant1.String() != tenant2.String() {
+			differ++
+		}
+	}
+	// With 5 members, about 4 in 5 keys map to different members.
+	if differ < 50 {
+		t.Fatalf("Expected the salts to map most of the keys independently, got %d of 100 different", differ)
+	}
+
+	if owner := c.LocateKeyWithSalt(nil, []byte("key")); owner.String() != c.LocateKey([]byte("key")).String() {
+		t.Fatalf("Expected the owner of the key without a salt, got %s", owner)
+	}
+}
+
+func TestWeightedConsistent_Weight(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 3},
+		testWeightedMember{name: "node3.olric", weight: 0},
+	}, cfg)
+
+	for name, expected := range map[string]int{"node1.olric": 1, "node2.olric": 3, "node3.olric": 1} {
+		if weight, ok := c.Weight(name); !ok || weight != expected {
+			t.Fatalf("Expected weight %d of %s, got %d and %v", expected, name, weight, ok)
+		}
+	}
+	if weight, ok := c.Weight("unknown"); ok || weight != 0 {
+		t.Fatalf("Expected no weight of an unknown member, got %d and %v", weight, ok)
+	}
+
+	if err := c.UpdateWeight("node1.olric", 2); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	c.Remove("node2.olric")
+	if weight, ok := c.Weight("node1.olric"); !ok || weight != 2 {
+		t.Fatalf("Expected weight 2 of node1.olric, got %d and %v", weight, ok)
+	}
+	if _, ok := c.Weight("node2.olric"); ok {
+		t.Fatal("Expected node2.olric to be removed")
+	}
+}
+
+func TestWeightedConsistent_ZeroConfigDefaults(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 2},
+	}
+	c := NewWeighted(members, WeightedConfig{})
+
+	cfg := c.Config()
+	if cfg.ReplicationFactor != DefaultReplicationFactor || cfg.Load != DefaultLoad {
+		t.Fatalf("Expected replication factor %d and load %v, got %d and %v",
+			DefaultReplicationFactor, DefaultLoad, cfg.ReplicationFactor, cfg.Load)
+	}
+	if cfg.PartitionCount != DefaultPartitionCount || c.PartitionCount() != DefaultPartitionCount {
+		t.Fatalf("Expected %d partitions, got %d", DefaultPartitionCount, c.PartitionCount())
+	}
+	if _, ok := c.Hasher().(DefaultHasher); !ok {
+		t.Fatalf("Expected DefaultHasher, got %T", c.Hasher())
+	}
+	if c.RingSize() != 3*DefaultReplicationFactor {
+		t.Fatalf("Expected %d positions, got %d", 3*DefaultReplicationFactor, c.RingSize())
+	}
+
+	// Many members get a larger suggested partition count.
+	members = make([]WeightedMember, 0, 50)
+	for i := 0; i < 50; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+	c = NewWeighted(members, WeightedConfig{})
+	if expected := SuggestPartitionCount(50, DefaultLoad); c.PartitionCount() != expected || expected <= DefaultPartitionCount {
+		t.Fatalf("Expected %d partitions, got %d", expected, c.PartitionCount())
+	}
+}
+
+func TestWeightedConsistent_ZeroWeight(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 0}, // Zero weight should be treated as 1
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	weights := c.WeightDistribution()
+	if weights["server1"] != 1 {
+		t.Fatalf("Expected zero weight to be treated as 1, got %d", weights["server1"])
+	}
+}
+
+func TestWeightedConsistent_TotalWeightRandomOrder(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(nil, cfg)
+	r := rand.New(rand.NewSource(42))
+	expected := make(map[string]int)
+
+	check := func() {
+		var sum int
+		for _, weight := range expected {
+			sum += weight
+		}
+		if c.GetTotalWeight() != sum {
+			t.Fatalf("Expected total weight %d, got %d", sum, c.GetTotalWeight())
+		}
+		if len(c.GetMembers()) != len(expected) {
+			t.Fatalf("Expected %d members, got %d", len(expected), len(c.GetMembers()))
+		}
+	}
+
+	for i := 0; i < 500; i++ {
+		name := fmt.Sprintf("node%d.olric", r.Intn(20))
+		if r.Intn(2) == 0 {
+			weight := r.Intn(5)
+			err := c.AddChecked(testWeightedMember{name: name, weight: weight})
+			if weight <= 0 {
+				weight = 1
+			}
+			if current, ok := expected[name]; !ok {
+				expected[name] = weight
+			} else if current != weight && err != ErrMemberConflict {
+				t.Fatalf("Expected ErrMemberConflict, got %v", err)
+			}
+		} else {
+			c.Remove(name)
+			delete(expected, name)
+		}
+		check()
+	}
+}
+
+func TestWeightedConsistent_AddMany(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted([]WeightedMember{testWeightedMember{name: "server1", weight: 2}}, cfg)
+	c.AddMany([]WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 3},
+		testWeightedMember{name: "server2", weight: 3},
+		testWeightedMember{name: "server3", weight: 1},
+	})
+
+	if len(c.GetMembers()) != 3 {
+		t.Fatalf("Expected 3 members, got %d", len(c.GetMembers()))
+	}
+	if c.GetTotalWeight() != 6 {
+		t.Fatalf("Expected total weight 6, got %d", c.GetTotalWeight())
+	}
+	if len(c.sortedSet) != 60 {
+		t.Fatalf("Expected 60 positions on the ring, got %d", len(c.sortedSet))
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID) == nil {
+			t.Fatalf("Partition %d has no owner", partID)
+		}
+	}
+
+	// A name with a different weight is rejected, whether it's in the ring or repeated in the batch.
+	for _, batch := range [][]WeightedMember{
+		{testWeightedMember{name: "server1", weight: 5}},
+		{testWeightedMember{name: "server4", weight: 1}, testWeightedMember{name: "server4", weight: 2}},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != ErrMemberConflict {
+					t.Fatalf("Expected ErrMemberConflict, got %v", r)
+				}
+			}()
+			c.AddMany(batch)
+		}()
+		if len(c.GetMembers()) != 3 || c.GetTotalWeight() != 6 {
+			t.Fatalf("Expected the ring to be unchanged, got %d members with total weight %d",
+				len(c.GetMembers()), c.GetTotalWeight())
+		}
+	}
+}
+
+func TestWeightedConsistent_MemberConflictPanics(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted([]WeightedMember{testWeightedMember{name: "server1", weight: 2}}, cfg)
+
+	// The same weight is a no-op.
+	c.Add(testWeightedMember{name: "server1", weight: 2})
+	c.AddMany([]WeightedMember{testWeightedMember{name: "server1", weight: 2}})
+	if c.GetTotalWeight() != 2 {
+		t.Fatalf("Expected total weight 2, got %d", c.GetTotalWeight())
+	}
+
+	conflicts := map[string]func(){
+		"Add": func() {
+			c.Add(testWeightedMember{name: "server1", weight: 3})
+		},
+		"AddMany": func() {
+			c.AddMany([]WeightedMember{testWeightedMember{name: "server1", weight: 3}})
+		},
+		"NewWeighted": func() {
+			NewWeighted([]WeightedMember{
+				testWeightedMember{name: "server2", weight: 1},
+				testWeightedMember{name: "server2", weight: 2},
+			}, cfg)
+		},
+	}
+	for name, fn := range conflicts {
+		func() {
+			defer func() {
+				if r := recover(); r != ErrMemberConflict {
+					t.Fatalf("Expected %s to panic with ErrMemberConflict, got %v", name, r)
+				}
+			}()
+			fn()
+		}()
+	}
+	if weight, _ := c.Weight("server1"); weight != 2 || c.GetTotalWeight() != 2 {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+}
+
+func TestWeightedConsistent_AddManyChecked(t *testing.T) {
+	// Partition 0 weighs more than Load times the fair share of any member once the others are added.
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		PartitionWeightFunc: func(partID int) float64 {
+			if partID == 0 {
+				return 1000
+			}
+			return 1
+		},
+	}
+
+	c := NewWeighted([]WeightedMember{testWeightedMember{name: "server1", weight: 1}}, cfg)
+	before := c.Clone()
+	err := c.AddManyChecked([]WeightedMember{
+		testWeightedMember{name: "server2", weight: 2},
+		testWeightedMember{name: "server3", weight: 1},
+	})
+	if !errors.Is(err, ErrNotEnoughRoom) {
+		t.Fatalf("Expected ErrNotEnoughRoom, got %v", err)
+	}
+	if len(c.GetMembers()) != 1 || c.GetTotalWeight() != 1 || len(c.sortedSet) != 10 || !c.Equal(before) {
+		t.Fatal("Expected the ring to be unchanged after a failed add")
+	}
+	if c.Adds() != 0 {
+		t.Fatalf("Expected 0 adds, got %d", c.Adds())
+	}
+
+	if err := c.AddManyChecked([]WeightedMember{testWeightedMember{name: "server1", weight: 3}}); err != ErrMemberConflict {
+		t.Fatalf("Expected ErrMemberConflict, got %v", err)
+	}
+	if err := c.AddManyChecked([]WeightedMember{testWeightedMember{name: "", weight: 1}}); err != ErrEmptyMemberName {
+		t.Fatalf("Expected ErrEmptyMemberName, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_RemoveMany(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 3},
+		testWeightedMember{name: "server3", weight: 1},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	c.RemoveMany([]string{"server1", "nonexistent", "server1", "server3"})
+
+	if len(c.GetMembers()) != 1 {
+		t.Fatalf("Expected 1 member, got %d", len(c.GetMembers()))
+	}
+	if c.GetTotalWeight() != 3 {
+		t.Fatalf("Expected total weight 3, got %d", c.GetTotalWeight())
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if owner := c.GetPartitionOwner(partID); owner == nil || owner.String() != "server2" {
+			t.Fatalf("Expected server2 to own partition %d, got %v", partID, owner)
+		}
+	}
+
+	c.RemoveMany([]string{"server2"})
+	if len(c.GetMembers()) != 0 {
+		t.Fatalf("Expected 0 members, got %d", len(c.GetMembers()))
+	}
+	if c.LocateKey([]byte("test-key")) != nil {
+		t.Fatal("Expected nil owner on an empty ring")
+	}
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Benchmark weighted consistent hash performance
+func BenchmarkWeightedConsistent_LocateKey(b *testing.B) {
+	members := make([]WeightedMember, 0, 100)
+	for i := 0; i < 100; i++ {
+		weight := (i % 5) + 1 // Weights from 1 to 5
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("server%d", i),
+			weight: weight,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	key := []byte("benchmark-key")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.LocateKey(key)
+	}
+}
+
+func TestWeightedConsistent_RedistributeAffected(t *testing.T) {
+	members := make([]WeightedMember, 0, 10)
+	for i := 0; i < 10; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("server%d", i),
+			weight: (i % 3) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	checkLoads := func() {
+		counts := make(map[string]float64)
+		for partID := 0; partID < cfg.PartitionCount; partID++ {
+			owner := c.GetPartitionOwner(partID)
+			if owner == nil {
+				t.Fatalf("Partition %d has no owner", partID)
+			}
+			counts[owner.String()]++
+		}
+		loads := c.LoadDistribution()
+		if len(loads) != len(counts) {
+			t.Fatalf("Expected %d entries in load distribution, got %d", len(counts), len(loads))
+		}
+		avgLoad := c.AverageLoad()
+		weights := c.WeightDistribution()
+		for name, count := range counts {
+			if loads[name] != count {
+				t.Fatalf("Expected load %.0f for %s, got %.0f", count, name, loads[name])
+			}
+			if count > avgLoad*float64(weights[name]) {
+				t.Fatalf("%s exceeds its expected load: %.0f", name, count)
+			}
+		}
+	}
+
+	before := make(map[int]string)
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		before[partID] = c.GetPartitionOwner(partID).String()
+	}
+
+	member := testWeightedMember{name: "server10", weight: 2}
+	c.Add(member)
+	checkLoads()
+
+	// A full rebuild with the same member set is the upper bound for the movement.
+	full := NewWeighted(append(members, member), cfg)
+	var moved, fullMoved int
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID).String() != before[partID] {
+			moved++
+		}
+		if full.GetPartitionOwner(partID).String() != before[partID] {
+			fullMoved++
+		}
+	}
+	if moved > fullMoved {
+		t.Fatalf("Incremental redistribution moved %d partitions, full rebuild moved %d", moved, fullMoved)
+	}
+
+	c.Remove("server3")
+	checkLoads()
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID).String() == "server3" {
+			t.Fatalf("Partition %d is still owned by the removed member", partID)
+		}
+	}
+}
+
+func BenchmarkWeightedConsistent_LocateKeyParallel(b *testing.B) {
+	members := make([]WeightedMember, 0, 100)
+	for i := 0; i < 100; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 5) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	key := []byte("benchmark-key")
+
+	// Locked reads the owner under the read lock, like LocateKey did before the partition table was published.
+	b.Run("Locked", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				partID := c.FindPartitionID(key)
+				c.mu.RLock()
+				c.getPartitionOwner(partID)
+				c.mu.RUnlock()
+			}
+		})
+	})
+
+	b.Run("LockFree", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.LocateKey(key)
+			}
+		})
+	})
+}
+
+func BenchmarkWeightedConsistent_LocateKeyString(b *testing.B) {
+	members := make([]WeightedMember, 0, 100)
+	for i := 0; i < 100; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 5) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	key := "benchmark-key"
+
+	b.Run("Bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.LocateKey([]byte(key))
+		}
+	})
+
+	b.Run("String", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.LocateKeyString(key)
+		}
+	})
+}
+
+func BenchmarkWeightedConsistent_LocateKeys(b *testing.B) {
+	members := make([]WeightedMember, 0, 100)
+	for i := 0; i < 100; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("server%d", i),
+			weight: (i % 5) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	keys := make([][]byte, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("key-%d", i)))
+	}
+
+	b.Run("LocateKey", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, key := range keys {
+				c.LocateKey(key)
+			}
+		}
+	})
+
+	b.Run("LocateKeys", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.LocateKeys(keys)
+		}
+	})
+}
+
+func BenchmarkWeightedConsistent_Redistribution(b *testing.B) {
+	members := make([]WeightedMember, 0, 100)
+	for i := 0; i < 100; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("server%d", i),
+			weight: (i % 5) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	partitions := c.partitions
+	member := testWeightedMember{name: "server100", weight: 3}
+	c.add(member)
+	added := map[string]struct{}{member.String(): {}}
+
+	b.Run("Full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.partitions = partitions
+			c.distributePartitions(context.Background())
+		}
+	})
+
+	b.Run("Incremental", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.partitions = partitions
+			c.redistributeAffected(context.Background(), added)
+		}
+	})
+}
+
+func TestWeightedConsistent_RemoveKeepsRingSorted(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 5},
+		testWeightedMember{name: "server3", weight: 1},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	c.Remove("server2")
+
+	if len(c.sortedSet) != 30 {
+		t.Fatalf("Expected 30 positions on the ring, got %d", len(c.sortedSet))
+	}
+	for i := 1; i < len(c.sortedSet); i++ {
+		if c.sortedSet[i-1] > c.sortedSet[i] {
+			t.Fatalf("Ring is not sorted at index %d", i)
+		}
+	}
+	for _, h := range c.sortedSet {
+		if (*c.ring[h]).String() == "server2" {
+			t.Fatal("Removed member still has positions on the ring")
+		}
+	}
+}
+
+func TestWeightedConsistent_AddKeepsRingSorted(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(nil, cfg)
+	for i := 0; i < 20; i++ {
+		c.Add(testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: (i % 4) + 1})
+		for j := 1; j < len(c.sortedSet); j++ {
+			if c.sortedSet[j-1] > c.sortedSet[j] {
+				t.Fatalf("Ring is not sorted at index %d after adding %d members", j, i+1)
+			}
+		}
+	}
+	if len(c.sortedSet) != 500 {
+		t.Fatalf("Expected 500 positions on the ring, got %d", len(c.sortedSet))
+	}
+}
+
+// heavyMemberRing builds a ring of 10k nodes and a heavy member for BenchmarkWeightedConsistent_RemoveHeavyMember.
+// It returns the ring, its sorted set and the hashes of the heavy member.
+func heavyMemberRing() (*WeightedConsistent, []uint64, []uint64) {
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(nil, cfg)
+
+	// Build a ring of 10k nodes directly, sorting it only once.
+	for i := 0; i < 10000; i++ {
+		var member WeightedMember = testWeightedMember{name: fmt.Sprintf("server%d", i), weight: 1}
+		for j := 0; j < cfg.ReplicationFactor; j++ {
+			h := c.hasher.Sum64(replicaKey(member.String(), j))
+			c.ring[h] = &member
+			c.sortedSet = append(c.sortedSet, h)
+		}
+	}
+	sort.Slice(c.sortedSet, func(i, j int) bool {
+		return c.sortedSet[i] < c.sortedSet[j]
+	})
+	heavy := testWeightedMember{name: "heavy", weight: 50}
+	c.add(heavy)
+
+	hashes := make([]uint64, 0, cfg.ReplicationFactor*heavy.weight)
+	for i := 0; i < cfg.ReplicationFactor*heavy.weight; i++ {
+		hashes = append(hashes, c.hasher.Sum64(replicaKey(heavy.name, i)))
+	}
+	return c, c.sortedSet, hashes
+}
+
+// delSliceOneByOne is the previous implementation of delSlice, which removes the hashes one by one,
+// kept as the baseline of BenchmarkWeightedConsistent_RemoveHeavyMember.
+func (c *WeightedConsistent) delSliceOneByOne(vals []uint64) {
+	for _, val := range vals {
+		for i := 0; i < len(c.sortedSet); i++ {
+			if c.sortedSet[i] == val {
+				c.sortedSet = append(c.sortedSet[:i], c.sortedSet[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkWeightedConsistent_RemoveHeavyMember(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		del  func(c *WeightedConsistent, vals []uint64)
+	}{
+		{"merge", (*WeightedConsistent).delSlice},
+		{"one-by-one", (*WeightedConsistent).delSliceOneByOne},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			c, sortedSet, hashes := heavyMemberRing()
+			scratch := make([]uint64, len(sortedSet))
+			vals := make([]uint64, len(hashes))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				copy(scratch, sortedSet)
+				copy(vals, hashes)
+				c.sortedSet = scratch
+				bm.del(c, vals)
+				if len(c.sortedSet) != len(sortedSet)-len(hashes) {
+					b.Fatalf("Expected %d positions on the ring, got %d", len(sortedSet)-len(hashes), len(c.sortedSet))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWeightedConsistent_AddMany(b *testing.B) {
+	members := make([]WeightedMember, 0, 50)
+	for i := 0; i < 50; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("server%d.olric", i),
+			weight: (i % 5) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	b.Run("Add", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c := NewWeighted(nil, cfg)
+			for _, member := range members {
+				c.Add(member)
+			}
+		}
+	})
+
+	b.Run("AddMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c := NewWeighted(nil, cfg)
+			c.AddMany(members)
+		}
+	})
+}
+
+func BenchmarkWeightedConsistent_AddMembersToRing(b *testing.B) {
+	members := make([]WeightedMember, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("server%d", i),
+			weight: (i % 5) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Only build the ring, distributing the partitions is out of scope.
+		c := NewWeighted(nil, cfg)
+		for _, member := range members {
+			c.add(member)
+		}
+	}
+}
+
+func BenchmarkWeightedConsistent_RepeatedAdd(b *testing.B) {
+	members := make([]WeightedMember, 0, 10)
+	for i := 0; i < 10; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 5) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    7919,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            DefaultHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	member := testWeightedMember{name: "node10.olric", weight: 3}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Add(member)
+		c.Remove(member.String())
+	}
+}
+
+func benchmarkReplicaSetsRing() *WeightedConsistent {
+	members := make([]WeightedMember, 0, 20)
+	for i := 0; i < 20; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 5) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    7919,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            DefaultHasher{},
+	}
+	return NewWeighted(members, cfg)
+}
+
+func BenchmarkWeightedConsistent_ReplicaSets(b *testing.B) {
+	c := benchmarkReplicaSetsRing()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.ReplicaSets(3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWeightedConsistent_ReplicaSetsPerPartition(b *testing.B) {
+	c := benchmarkReplicaSetsRing()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sets := make(map[int][]WeightedMember, c.PartitionCount())
+		for partID := 0; partID < c.PartitionCount(); partID++ {
+			set, err := c.GetClosestNForPartition(partID, 3)
+			if err != nil {
+				b.Fatal(err)
+			}
+			sets[partID] = set
+		}
 	}
 }
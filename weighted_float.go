@@ -0,0 +1,144 @@
+package consistent
+
+import "math"
+
+// FloatWeightedMember represents a member with a fractional weight, e.g. a capacity score of 2.7 CPUs.
+type FloatWeightedMember interface {
+	Member
+	WeightF() float64
+}
+
+// floatWeightedMember adapts a FloatWeightedMember to WeightedMember. Its weight is the number of its
+// replicas, so the original member can be recovered with a type assertion to FloatWeightedMember.
+type floatWeightedMember struct {
+	FloatWeightedMember
+	replicas int
+}
+
+func (m floatWeightedMember) Weight() int {
+	return m.replicas
+}
+
+// floatReplicas returns the number of replicas of a member with the given float weight: the weight
+// multiplied by the replication factor, rounded to the nearest integer. Every member has at least one
+// replica, so weights below 0.5/replicationFactor are rounded up.
+func floatReplicas(weight float64, replicationFactor int) int {
+	replicas := int(math.Round(weight * float64(replicationFactor)))
+	if replicas < 1 {
+		replicas = 1
+	}
+	return replicas
+}
+
+// NewWeightedFloat creates and returns a new WeightedConsistent object for members with float weights.
+// Each member gets round(config.ReplicationFactor * WeightF()) replicas, at least one, and its load is
+// bounded in proportion to that number. The ring measures the weights in replicas, so GetMembers and
+// WeightDistribution report the replica counts and the replication factor of the ring is 1; the members
+// can be converted back to FloatWeightedMember. Use AddFloat to add more members. It returns the same
+// errors as NewWeightedChecked.
+func NewWeightedFloat(members []FloatWeightedMember, config WeightedConfig) (*WeightedConsistent, error) {
+	scale := config.ReplicationFactor
+	if scale == 0 {
+		scale = DefaultReplicationFactor
+	}
+	config.ReplicationFactor = 1
+
+	var weighted []WeightedMember
+	if members != nil {
+		weighted = make([]WeightedMember, 0, len(members))
+	}
+	for _, member := range members {
+		weighted = append(weighted, floatWeightedMember{member, floatReplicas(member.WeightF(), scale)})
+	}
+	c, err := NewWeightedChecked(weighted, config)
+	if err != nil {
+		return nil, err
+	}
+	c.floatScale = scale
+	return c, nil
+}
+
+// AddFloat adds a member with a float weight to a ring created by NewWeightedFloat, like AddChecked.
+// On other rings the weight is rounded to the nearest integer, at least 1.
+func (c *WeightedConsistent) AddFloat(member FloatWeightedMember) error {
+	c.mu.RLock()
+	scale := c.floatScale
+	if scale == 0 {
+		scale = 1
+	}
+	c.mu.RUnlock()
+
+	return c.AddChecked(floatWeightedMember{member, floatReplicas(member.WeightF(), scale)})
+}
+
+// normalizedMember wraps a member whose weight was scaled down by NormalizeWeights.
+type normalizedMember struct {
+	WeightedMember
+	weight int
+}
+
+func (m normalizedMember) Weight() int {
+	return m.weight
+}
+
+// NormalizeWeights scales the weights of the members down proportionally so that the ring places at most
+// maxTotalReplicas replicas, e.g. when the weights are raw capacities like the memory in GB and would place
+// too many replicas on the ring. The ring places replicationFactor replicas per unit of weight, so the total
+// weight is bounded by maxTotalReplicas divided by replicationFactor. DefaultReplicationFactor is used if
+// replicationFactor is not positive; pass the ReplicationFactor of the ring's config. MinReplicasPerMember
+// isn't taken into account. The weights are rounded down, so the ratios are preserved within rounding. Every
+// member keeps a weight of at least 1, the members which would round down to 0 take 1 from the budget of the
+// others, so the replicas exceed maxTotalReplicas only if there are more members than the budget allows.
+//
+// The result is a new slice, the members are wrapped to change their weights only. If the replicas already
+// fit or maxTotalReplicas is not positive, it holds the original members.
+func NormalizeWeights(members []WeightedMember, maxTotalReplicas, replicationFactor int) []WeightedMember {
+	if replicationFactor <= 0 {
+		replicationFactor = DefaultReplicationFactor
+	}
+	res := make([]WeightedMember, len(members))
+	weights := make([]int64, len(members))
+	var total int64
+	for i, member := range members {
+		weights[i] = int64(member.Weight())
+		if weights[i] <= 0 {
+			weights[i] = 1 // Ensure minimum weight of 1
+		}
+		total += weights[i]
+	}
+	if maxTotalReplicas <= 0 || total*int64(replicationFactor) <= int64(maxTotalReplicas) {
+		copy(res, members)
+		return res
+	}
+
+	// The members rounding down to 0 are clamped to 1 and the others share the rest of the budget,
+	// which may make more members round down to 0.
+	clamped := make([]bool, len(members))
+	budget, scaled := int64(maxTotalReplicas/replicationFactor), total
+	for {
+		changed := false
+		for i, weight := range weights {
+			if clamped[i] || weight*budget/scaled > 0 {
+				continue
+			}
+			clamped[i] = true
+			budget--
+			scaled -= weight
+			changed = true
+		}
+		if !changed || budget <= 0 || scaled == 0 {
+			break
+		}
+	}
+	for i, member := range members {
+		weight := int64(1)
+		if !clamped[i] && budget > 0 {
+			weight = weights[i] * budget / scaled
+		}
+		if weight < 1 {
+			weight = 1
+		}
+		res[i] = normalizedMember{member, int(weight)}
+	}
+	return res
+}
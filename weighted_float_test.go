@@ -0,0 +1,142 @@
+package consistent
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+type floatTestMember struct {
+	name   string
+	weight float64
+}
+
+func (m floatTestMember) String() string {
+	return m.name
+}
+
+func (m floatTestMember) WeightF() float64 {
+	return m.weight
+}
+
+func TestNewWeightedFloat(t *testing.T) {
+	members := []FloatWeightedMember{
+		floatTestMember{name: "node1.olric", weight: 1.0},
+		floatTestMember{name: "node2.olric", weight: 2.5},
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    1021,
+		ReplicationFactor: 100,
+		Load:              1.1,
+	}
+	c, err := NewWeightedFloat(members, cfg)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if n := c.ReplicaCount("node2.olric"); n != 250 {
+		t.Fatalf("Expected 250 replicas for node2.olric, got %d", n)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 100000; i++ {
+		owner := c.LocateKey([]byte(fmt.Sprintf("key-%d", i)))
+		member, ok := owner.(FloatWeightedMember)
+		if !ok {
+			t.Fatalf("Expected a FloatWeightedMember, got %T", owner)
+		}
+		counts[member.String()]++
+	}
+	ratio := float64(counts["node2.olric"]) / float64(counts["node1.olric"])
+	if math.Abs(ratio-2.5) > 0.3 {
+		t.Fatalf("Expected a key ratio of about 2.5, got %.2f (%v)", ratio, counts)
+	}
+
+	if err := c.AddFloat(floatTestMember{name: "node3.olric", weight: 0.001}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if n := c.ReplicaCount("node3.olric"); n != 1 {
+		t.Fatalf("Expected the minimum of 1 replica for node3.olric, got %d", n)
+	}
+	if err := c.AddFloat(floatTestMember{name: "node4.olric", weight: 0.75}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if n := c.ReplicaCount("node4.olric"); n != 75 {
+		t.Fatalf("Expected 75 replicas for node4.olric, got %d", n)
+	}
+}
+
+func TestNormalizeWeights(t *testing.T) {
+	// The weights are the memory of the members in GB.
+	gigabytes := []int{64, 128, 256, 512, 1000}
+	members := make([]WeightedMember, 0, len(gigabytes))
+	var total int
+	for i, weight := range gigabytes {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: weight})
+		total += weight
+	}
+
+	const (
+		maxTotalReplicas  = 1000
+		replicationFactor = 10
+	)
+	normalized := NormalizeWeights(members, maxTotalReplicas, replicationFactor)
+	if len(normalized) != len(members) {
+		t.Fatalf("Expected %d members, got %d", len(members), len(normalized))
+	}
+	var sum int
+	for i, member := range normalized {
+		if member.String() != members[i].String() {
+			t.Fatalf("Expected %s, got %s", members[i], member)
+		}
+		// The ratios are preserved within rounding.
+		expected := float64(gigabytes[i]) * maxTotalReplicas / replicationFactor / float64(total)
+		if diff := expected - float64(member.Weight()); diff < 0 || diff >= 1 {
+			t.Fatalf("Expected a weight of %f rounded down for %s, got %d", expected, member, member.Weight())
+		}
+		sum += member.Weight()
+	}
+	if sum*replicationFactor > maxTotalReplicas {
+		t.Fatalf("Expected at most %d replicas, got %d", maxTotalReplicas, sum*replicationFactor)
+	}
+	if members[0].Weight() != 64 {
+		t.Fatalf("Expected the original members to be unchanged, got %d", members[0].Weight())
+	}
+
+	c := NewWeighted(normalized, WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: replicationFactor,
+		Hasher:            testWeightedHasher{},
+	})
+	if c.RingSize() > maxTotalReplicas {
+		t.Fatalf("Expected at most %d replicas, got %d", maxTotalReplicas, c.RingSize())
+	}
+
+	// The small members keep a weight of 1 and the others share the rest of the budget.
+	normalized = NormalizeWeights([]WeightedMember{
+		testWeightedMember{name: "small1", weight: 1},
+		testWeightedMember{name: "small2", weight: 2},
+		testWeightedMember{name: "large", weight: 1000},
+	}, 100, replicationFactor)
+	if normalized[0].Weight() != 1 || normalized[1].Weight() != 1 || normalized[2].Weight() != 8 {
+		t.Fatalf("Expected weights 1, 1 and 8, got %d, %d and %d",
+			normalized[0].Weight(), normalized[1].Weight(), normalized[2].Weight())
+	}
+
+	// The members which fit are returned as they are.
+	normalized = NormalizeWeights(members, total*replicationFactor, replicationFactor)
+	for i, member := range normalized {
+		if member != members[i] {
+			t.Fatalf("Expected %v, got %v", members[i], member)
+		}
+	}
+
+	// DefaultReplicationFactor is used if the replication factor is not positive.
+	normalized = NormalizeWeights(members, 100*DefaultReplicationFactor, 0)
+	sum = 0
+	for _, member := range normalized {
+		sum += member.Weight()
+	}
+	if sum > 100 {
+		t.Fatalf("Expected the total weight to be at most 100, got %d", sum)
+	}
+}
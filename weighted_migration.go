@@ -0,0 +1,174 @@
+package consistent
+
+import (
+	"context"
+	"time"
+)
+
+// PartitionMove represents a partition whose owner differs between two ring states.
+type PartitionMove struct {
+	PartitionID int
+	From        string
+	To          string
+}
+
+// partitionOwners returns the owner names indexed by partition ID. Unowned partitions have an empty name.
+// It's not thread-safe.
+func (c *WeightedConsistent) partitionOwners() []string {
+	owners := make([]string, c.partitionCount)
+	for partID, member := range c.partitions {
+		owners[partID] = (*member).String()
+	}
+	return owners
+}
+
+// PartitionOwners returns the owner names indexed by partition ID, so its length is the partition count. Unowned
+// partitions, e.g. on an empty ring, have an empty name. It's the slice form of PartitionTable: the owner of
+// a key is PartitionOwners()[FindPartitionID(key)] and the slice is a cheap snapshot of the partition table.
+func (c *WeightedConsistent) PartitionOwners() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.partitionOwners()
+}
+
+// MigrationPlan lists every partition whose owner differs between the old and the new ring, ordered by
+// partition ID. It compares the partition tables, so no key is hashed. If the partition counts differ,
+// partitions which only exist in one of the rings have an empty From or To.
+func MigrationPlan(old, new *WeightedConsistent) []PartitionMove {
+	return migrationPlan(old.PartitionOwners(), new.PartitionOwners())
+}
+
+// migrationPlan is MigrationPlan on the owner names indexed by partition ID, see PartitionOwners.
+func migrationPlan(oldOwners, newOwners []string) []PartitionMove {
+	count := len(oldOwners)
+	if len(newOwners) > count {
+		count = len(newOwners)
+	}
+
+	var moves []PartitionMove
+	for partID := 0; partID < count; partID++ {
+		var from, to string
+		if partID < len(oldOwners) {
+			from = oldOwners[partID]
+		}
+		if partID < len(newOwners) {
+			to = newOwners[partID]
+		}
+		if from != to {
+			moves = append(moves, PartitionMove{PartitionID: partID, From: from, To: to})
+		}
+	}
+	return moves
+}
+
+// estimateClone returns a clone of the ring whose changes aren't reported to the MetricsObserver or the
+// OnDistributeFailure callback, and the partition owners of the ring taken under the same lock.
+func (c *WeightedConsistent) estimateClone() (*WeightedConsistent, []string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clone := c.clone()
+	clone.config.MetricsObserver = nopMetricsObserver{}
+	if clone.config.OnDistributeFailure != nil {
+		// The partitions which don't fit are still left unassigned, like the actual change would do.
+		clone.config.OnDistributeFailure = func(int) {}
+	}
+	return clone, c.partitionOwners()
+}
+
+// EstimateAddImpact reports how many partitions would move if the member was added, and which fraction of
+// the partitions that is, without changing the ring: the member is added to a clone of the ring and the
+// partition tables are compared like MigrationPlan does. It returns 0 moves if the member is already in the
+// ring or it would be rejected, since the add would leave the ring unchanged. The OnDistributeFailure callback
+// and the MetricsObserver aren't notified about the hypothetical change.
+func (c *WeightedConsistent) EstimateAddImpact(member WeightedMember) (partitionsMoved int, fraction float64) {
+	clone, before := c.estimateClone()
+	if err := clone.AddChecked(member); err != nil {
+		return 0, 0
+	}
+	return impact(before, clone)
+}
+
+// EstimateRemoveImpact is like EstimateAddImpact but for removing the member with the given name. It returns
+// 0 moves for unknown members and if the partitions of the member wouldn't fit on the others, where Remove
+// panics.
+func (c *WeightedConsistent) EstimateRemoveImpact(name string) (partitionsMoved int, fraction float64) {
+	clone, before := c.estimateClone()
+	if !clone.Has(name) {
+		return 0, 0
+	}
+	// The clone isn't shared, so it's changed without taking its lock.
+	clone.remove(name)
+	if len(clone.members) == 0 {
+		clone.setPartitions(make(map[int]*WeightedMember), make(map[string]float64), time.Now())
+	} else if err := clone.redistributeAffected(context.Background(), nil); err != nil {
+		return 0, 0
+	}
+	return impact(before, clone)
+}
+
+// impact counts the partitions whose owners differ between the owners before the change and the changed
+// clone of the ring.
+func impact(before []string, changed *WeightedConsistent) (int, float64) {
+	moved := len(migrationPlan(before, changed.PartitionOwners()))
+	return moved, float64(moved) / float64(changed.PartitionCount())
+}
+
+// ApplyMoves overrides the owners of the partitions in moves with their To members, so an orchestrator can
+// apply a MigrationPlan in batches and rate-limit the data movement. From is ignored. The moves are
+// validated first: it returns ErrInvalidPartitionID if a partition ID is out of range and ErrMemberNotFound
+// if a target isn't in the ring, the ring is left unchanged in both cases. Otherwise the moves are applied
+// like a redistribution: the OnPartitionMoved callback and the MetricsObserver are notified once.
+//
+// The owners are set as given, so the bounded-load invariant may not hold until the whole plan is applied:
+// a member may temporarily own more than its expected load. The next change of the ring redistributes
+// the partitions of the overloaded members. If a target is one of the backups of the partition, see
+// ReplicaSetSize, it's removed from the backups.
+func (c *WeightedConsistent) ApplyMoves(moves []PartitionMove) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, move := range moves {
+		if move.PartitionID < 0 || move.PartitionID >= int(c.partitionCount) {
+			return ErrInvalidPartitionID
+		}
+		if _, ok := c.members[move.To]; !ok {
+			return ErrMemberNotFound
+		}
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+
+	started := time.Now()
+	partitions := make(map[int]*WeightedMember, len(c.partitions))
+	for partID, member := range c.partitions {
+		partitions[partID] = member
+	}
+	loads := make(map[string]float64, len(c.loads))
+	for name, load := range c.loads {
+		loads[name] = load
+	}
+	for _, move := range moves {
+		pw := c.partitionWeight(move.PartitionID)
+		if owner, ok := partitions[move.PartitionID]; ok {
+			loads[(*owner).String()] -= pw
+		}
+		partitions[move.PartitionID] = c.members[move.To]
+		loads[move.To] += pw
+
+		set := c.backups[move.PartitionID]
+		for i, member := range set {
+			if (*member).String() == move.To {
+				// The target already holds the partition as a backup, it's counted once.
+				backups := make([]*WeightedMember, 0, len(set)-1)
+				c.backups[move.PartitionID] = append(append(backups, set[:i]...), set[i+1:]...)
+				loads[move.To] -= pw
+				break
+			}
+		}
+	}
+	c.setPartitions(partitions, loads, started)
+	return nil
+}
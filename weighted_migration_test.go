@@ -0,0 +1,286 @@
+package consistent
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMigrationPlan(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 3) + 1,
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	old := NewWeighted(members, cfg)
+	if plan := MigrationPlan(old, NewWeighted(members, cfg)); len(plan) != 0 {
+		t.Fatalf("Expected an empty plan for identical rings, got %d moves", len(plan))
+	}
+
+	updated := NewWeighted(members, cfg)
+	updated.Add(testWeightedMember{name: "node8.olric", weight: 2})
+
+	plan := MigrationPlan(old, updated)
+	if len(plan) == 0 {
+		t.Fatal("Expected some partitions to move")
+	}
+	if len(plan) >= cfg.PartitionCount/2 {
+		t.Fatalf("Expected a minority of partitions to move, got %d of %d", len(plan), cfg.PartitionCount)
+	}
+
+	for i, move := range plan {
+		if i > 0 && plan[i-1].PartitionID >= move.PartitionID {
+			t.Fatalf("Plan is not ordered by partition ID")
+		}
+		if move.From != old.GetPartitionOwner(move.PartitionID).String() {
+			t.Fatalf("Expected %s as the old owner of %d, got %s", old.GetPartitionOwner(move.PartitionID), move.PartitionID, move.From)
+		}
+		if move.To != updated.GetPartitionOwner(move.PartitionID).String() {
+			t.Fatalf("Expected %s as the new owner of %d, got %s", updated.GetPartitionOwner(move.PartitionID), move.PartitionID, move.To)
+		}
+		if move.From == move.To {
+			t.Fatalf("Partition %d doesn't move", move.PartitionID)
+		}
+	}
+
+	moved := make(map[int]struct{})
+	for _, move := range plan {
+		moved[move.PartitionID] = struct{}{}
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if _, ok := moved[partID]; ok {
+			continue
+		}
+		if old.GetPartitionOwner(partID).String() != updated.GetPartitionOwner(partID).String() {
+			t.Fatalf("Partition %d moved but it's not in the plan", partID)
+		}
+	}
+}
+
+func TestEstimateImpact(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    1021,
+		ReplicationFactor: 50,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	const n = 8
+	members := make([]WeightedMember, 0, n)
+	for i := 0; i < n; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+	c := NewWeighted(members, cfg)
+	before := c.Clone()
+
+	added := testWeightedMember{name: "node8.olric", weight: 1}
+	moved, fraction := c.EstimateAddImpact(added)
+	if !c.Equal(before) || c.Has(added.name) || c.Version() != before.Version() {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+	// The added member takes about 1/(N+1) of the partitions, the bounded loads move a few more.
+	if expected := 1.0 / (n + 1); fraction < expected/2 || fraction > 2*expected {
+		t.Fatalf("Expected a fraction near %f, got %f", expected, fraction)
+	}
+	if fraction != float64(moved)/float64(cfg.PartitionCount) {
+		t.Fatalf("Expected the fraction of %d moves, got %f", moved, fraction)
+	}
+
+	// The estimate matches the actual change.
+	updated := c.Clone()
+	updated.Add(added)
+	if plan := MigrationPlan(c, updated); len(plan) != moved {
+		t.Fatalf("Expected %d moves, got %d", len(plan), moved)
+	}
+
+	moved, fraction = c.EstimateRemoveImpact("node0.olric")
+	if !c.Equal(before) || !c.Has("node0.olric") {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+	if owned := len(c.OwnedPartitions("node0.olric")); moved != owned {
+		t.Fatalf("Expected the %d partitions of node0.olric to move, got %d", owned, moved)
+	}
+	if expected := 1.0 / n; fraction < expected/2 || fraction > 2*expected {
+		t.Fatalf("Expected a fraction near %f, got %f", expected, fraction)
+	}
+
+	if moved, fraction := c.EstimateAddImpact(members[0]); moved != 0 || fraction != 0 {
+		t.Fatalf("Expected no moves for an existing member, got %d and %f", moved, fraction)
+	}
+	if moved, fraction := c.EstimateRemoveImpact("unknown"); moved != 0 || fraction != 0 {
+		t.Fatalf("Expected no moves for an unknown member, got %d and %f", moved, fraction)
+	}
+}
+
+func TestEstimateImpact_OnDistributeFailure(t *testing.T) {
+	var failed []int
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		// Partition 0 doesn't fit any member.
+		PartitionWeightFunc: func(partID int) float64 {
+			if partID == 0 {
+				return 200
+			}
+			return 1
+		},
+		OnDistributeFailure: func(partID int) {
+			failed = append(failed, partID)
+		},
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 1},
+	}, cfg)
+	failed = nil
+
+	added := testWeightedMember{name: "node3.olric", weight: 1}
+	moved, _ := c.EstimateAddImpact(added)
+	if len(failed) != 0 {
+		t.Fatalf("Expected no callback for the estimate, got %v", failed)
+	}
+	if moved == 0 {
+		t.Fatal("Expected some partitions to move")
+	}
+
+	// The estimate matches the actual change, which leaves partition 0 unassigned as well.
+	updated := c.Clone()
+	updated.Add(added)
+	if plan := MigrationPlan(c, updated); len(plan) != moved {
+		t.Fatalf("Expected %d moves, got %d", len(plan), moved)
+	}
+	if !reflect.DeepEqual(failed, []int{0}) {
+		t.Fatalf("Expected the callback for partition 0 of the actual change, got %v", failed)
+	}
+}
+
+func TestApplyMoves(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 3) + 1,
+		})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	target := c.Clone()
+	target.Remove("node0.olric")
+	plan := MigrationPlan(c, target)
+	if len(plan) < 2 {
+		t.Fatalf("Expected some partitions to move, got %d", len(plan))
+	}
+
+	// A partial plan moves only the given partitions.
+	before := c.PartitionTable()
+	half := plan[:len(plan)/2]
+	if err := c.ApplyMoves(half); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	applied := make(map[int]string)
+	for _, move := range half {
+		applied[move.PartitionID] = move.To
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		expected, ok := applied[partID]
+		if !ok {
+			expected = before[partID]
+		}
+		if owner := c.GetPartitionOwner(partID).String(); owner != expected {
+			t.Fatalf("Expected %s as the owner of %d, got %s", expected, partID, owner)
+		}
+	}
+	var total float64
+	for _, load := range c.LoadDistribution() {
+		total += load
+	}
+	if total != float64(cfg.PartitionCount) {
+		t.Fatalf("Expected the loads to sum up to %d, got %v", cfg.PartitionCount, total)
+	}
+	if load := c.LoadDistribution()["node0.olric"]; load != float64(len(plan)-len(half)) {
+		t.Fatalf("Expected node0.olric to keep %d partitions, got %v", len(plan)-len(half), load)
+	}
+
+	// The rest of the plan completes the migration.
+	if err := c.ApplyMoves(plan[len(half):]); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !reflect.DeepEqual(c.PartitionTable(), target.PartitionTable()) {
+		t.Fatal("Expected the partition table of the plan")
+	}
+	if len(c.OwnedPartitions("node0.olric")) != 0 {
+		t.Fatalf("Expected node0.olric to own no partitions, got %v", c.OwnedPartitions("node0.olric"))
+	}
+
+	snapshot := c.Clone()
+	if err := c.ApplyMoves([]PartitionMove{{PartitionID: 0, To: "node1.olric"}, {PartitionID: 1, To: "unknown"}}); err != ErrMemberNotFound {
+		t.Fatalf("Expected ErrMemberNotFound, got: %v", err)
+	}
+	if err := c.ApplyMoves([]PartitionMove{{PartitionID: cfg.PartitionCount, To: "node1.olric"}}); err != ErrInvalidPartitionID {
+		t.Fatalf("Expected ErrInvalidPartitionID, got: %v", err)
+	}
+	if !c.Equal(snapshot) {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+}
+
+func TestPartitionOwners(t *testing.T) {
+	members := make([]WeightedMember, 0, 8)
+	for i := 0; i < 8; i++ {
+		members = append(members, testWeightedMember{
+			name:   fmt.Sprintf("node%d.olric", i),
+			weight: (i % 3) + 1,
+		})
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	owners := c.PartitionOwners()
+	if len(owners) != cfg.PartitionCount {
+		t.Fatalf("Expected %d owners, got %d", cfg.PartitionCount, len(owners))
+	}
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if owner := owners[c.FindPartitionID(key)]; owner != c.LocateKey(key).String() {
+			t.Fatalf("Expected %s for %s, got %s", c.LocateKey(key), key, owner)
+		}
+	}
+	for partID, owner := range c.PartitionTable() {
+		if owners[partID] != owner {
+			t.Fatalf("Expected %s as the owner of %d, got %s", owner, partID, owners[partID])
+		}
+	}
+
+	// The slice is a snapshot.
+	c.Add(testWeightedMember{name: "node8.olric", weight: 2})
+	if reflect.DeepEqual(owners, c.PartitionOwners()) {
+		t.Fatal("Expected the partition table to change")
+	}
+
+	owners = NewWeighted(nil, cfg).PartitionOwners()
+	if len(owners) != cfg.PartitionCount || owners[0] != "" {
+		t.Fatalf("Expected %d unowned partitions, got %v", cfg.PartitionCount, owners[:1])
+	}
+}
@@ -0,0 +1,209 @@
+package consistent
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ReconcileResult summarizes the changes applied by Reconcile. The names are sorted.
+type ReconcileResult struct {
+	Added   []string
+	Removed []string
+	// Updated lists the members whose weights changed.
+	Updated []string
+	// Moved is the number of partitions whose owner changed.
+	Moved int
+}
+
+// Changed reports whether Reconcile changed the members of the ring.
+func (r ReconcileResult) Changed() bool {
+	return len(r.Added) != 0 || len(r.Removed) != 0 || len(r.Updated) != 0
+}
+
+// Reconcile makes the members of the ring equal to the desired members: the missing members are added,
+// the members which aren't desired are removed and the weights which differ are updated, like
+// AddOrUpdate. The partitions are redistributed only once. If a name is repeated in desired, the last
+// member wins.
+//
+// It returns ErrTooManyReplicas if a desired member exceeds MaxReplicasPerMember, ErrHashKeyConflict if two
+// desired members have the same hash key, ErrEmptyMemberName if a name is empty and ErrNotEnoughRoom if the partitions cannot be distributed, the
+// ring is left unchanged in all the cases.
+func (c *WeightedConsistent) Reconcile(desired []WeightedMember) (ReconcileResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	want := make(map[string]WeightedMember, len(desired))
+	weights := make(map[string]int, len(desired))
+	for _, member := range desired {
+		if member.String() == "" {
+			return ReconcileResult{}, ErrEmptyMemberName
+		}
+		weight := member.Weight()
+		if weight <= 0 {
+			weight = 1 // Ensure minimum weight of 1
+		}
+		want[member.String()] = member
+		weights[member.String()] = weight
+	}
+
+	keys := make(map[string]string, len(want))
+	for name, member := range want {
+		if other, ok := keys[memberHashKey(member)]; ok && other != name {
+			return ReconcileResult{}, ErrHashKeyConflict
+		}
+		keys[memberHashKey(member)] = name
+	}
+
+	var res ReconcileResult
+	for name, weight := range weights {
+		if err := c.checkReplicas(weight); err != nil {
+			return ReconcileResult{}, err
+		}
+		current, ok := c.weights[name]
+		if weight, draining := c.draining[name]; draining {
+			current = weight
+		}
+		if !ok {
+			res.Added = append(res.Added, name)
+		} else if current != weight {
+			res.Updated = append(res.Updated, name)
+		}
+	}
+	for name := range c.members {
+		if _, ok := want[name]; !ok {
+			res.Removed = append(res.Removed, name)
+		}
+	}
+	if !res.Changed() {
+		return res, nil
+	}
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	sort.Strings(res.Updated)
+
+	// Keep what's needed to revert the changes if the partitions cannot be distributed.
+	removed := make(map[string]WeightedMember, len(res.Removed))
+	oldWeights := make(map[string]int, len(res.Removed)+len(res.Updated))
+	drained := make(map[string]int)
+	for _, name := range res.Removed {
+		removed[name] = *c.members[name]
+		oldWeights[name] = c.weights[name]
+		if weight, ok := c.draining[name]; ok {
+			drained[name] = weight
+		}
+		c.remove(name)
+	}
+
+	// The added members and the members whose weights grew may take partitions from the others.
+	added := make(map[string]struct{}, len(res.Added)+len(res.Updated))
+	for _, name := range res.Updated {
+		if weight, ok := c.draining[name]; ok {
+			// Like UpdateWeight, only change the weight to restore of a draining member.
+			oldWeights[name] = weight
+			c.draining[name] = weights[name]
+			continue
+		}
+		oldWeights[name] = c.weights[name]
+		if weights[name] > c.weights[name] {
+			added[name] = struct{}{}
+		}
+		c.reweight(name, weights[name])
+	}
+	for _, name := range res.Added {
+		c.addWithWeight(want[name], weights[name])
+		added[name] = struct{}{}
+	}
+
+	old := c.partitions
+	if len(c.members) == 0 {
+		// consistent hash ring is empty now. Reset the partition table.
+		c.setPartitions(make(map[int]*WeightedMember), make(map[string]float64), time.Now())
+	} else if err := c.redistributeAffected(context.Background(), added); err != nil {
+		for _, name := range res.Added {
+			c.remove(name)
+		}
+		for _, name := range res.Updated {
+			if _, ok := c.draining[name]; ok {
+				c.draining[name] = oldWeights[name]
+				continue
+			}
+			c.reweight(name, oldWeights[name])
+		}
+		for _, name := range res.Removed {
+			c.addWithWeight(removed[name], oldWeights[name])
+		}
+		for name, weight := range drained {
+			c.draining[name] = weight
+		}
+		return ReconcileResult{}, err
+	}
+
+	atomic.AddUint64(&c.counters.adds, uint64(len(res.Added)))
+	atomic.AddUint64(&c.counters.removes, uint64(len(res.Removed)))
+	for partID := 0; partID < int(c.partitionCount); partID++ {
+		from, to := old[partID], c.partitions[partID]
+		if (from == nil) != (to == nil) || (from != nil && (*from).String() != (*to).String()) {
+			res.Moved++
+		}
+	}
+	return res, nil
+}
+
+// Swap replaces all the members of the ring with the given members at once. The new ring is built aside
+// from scratch, like NewWeightedChecked with the config of the ring, and swapped in under a single write
+// lock, so the concurrent readers, including LocateKey, see either the old or the new ring, never an empty
+// or partial one. Unlike Reconcile, the partitions are distributed from scratch, so they may move between
+// the members which are kept as well. The draining states are dropped. An empty or nil slice empties the
+// ring. It returns the errors of NewWeightedChecked, the ring is left unchanged in that case.
+func (c *WeightedConsistent) Swap(members []WeightedMember) error {
+	started := time.Now()
+	c.mu.RLock()
+	config := c.config
+	c.mu.RUnlock()
+
+	next, err := buildSwap(members, config)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if next.partitionCount != c.partitionCount {
+		// The partition count changed while the new ring was built.
+		if next, err = buildSwap(members, c.config); err != nil {
+			return err
+		}
+	}
+
+	var added, removed int
+	for name := range next.members {
+		if _, ok := c.members[name]; !ok {
+			added++
+		}
+	}
+	for name := range c.members {
+		if _, ok := next.members[name]; !ok {
+			removed++
+		}
+	}
+	c.sortedSet = next.sortedSet
+	c.ring = next.ring
+	c.members = next.members
+	c.weights = next.weights
+	c.totalWeight = next.totalWeight
+	c.backups = next.backups
+	c.draining = nil
+	atomic.AddUint64(&c.counters.adds, uint64(added))
+	atomic.AddUint64(&c.counters.removes, uint64(removed))
+	c.setPartitions(next.partitions, next.loads, started)
+	return nil
+}
+
+// buildSwap builds the ring which Swap swaps in. The MetricsObserver is only notified by the swap itself.
+func buildSwap(members []WeightedMember, config WeightedConfig) (*WeightedConsistent, error) {
+	config.MetricsObserver = nopMetricsObserver{}
+	return NewWeightedChecked(members, config)
+}
@@ -0,0 +1,236 @@
+package consistent
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	observer := &testMetricsObserver{}
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		MetricsObserver:   observer,
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "a", weight: 1},
+		testWeightedMember{name: "b", weight: 2},
+	}, cfg)
+
+	observer.redistributions = 0
+	res, err := c.Reconcile([]WeightedMember{
+		testWeightedMember{name: "b", weight: 2},
+		testWeightedMember{name: "c", weight: 3},
+	})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !reflect.DeepEqual(res.Added, []string{"c"}) || !reflect.DeepEqual(res.Removed, []string{"a"}) || len(res.Updated) != 0 {
+		t.Fatalf("Expected c to be added and a to be removed, got %+v", res)
+	}
+	if observer.redistributions != 1 {
+		t.Fatalf("Expected 1 redistribution, got %d", observer.redistributions)
+	}
+	if res.Moved == 0 {
+		t.Fatal("Expected some partitions to move")
+	}
+	if !reflect.DeepEqual(c.WeightDistribution(), map[string]int{"b": 2, "c": 3}) {
+		t.Fatalf("Unexpected weights: %v", c.WeightDistribution())
+	}
+	if c.GetTotalWeight() != 5 || c.RingSize() != 5*cfg.ReplicationFactor {
+		t.Fatalf("Expected total weight 5 and %d positions, got %d and %d",
+			5*cfg.ReplicationFactor, c.GetTotalWeight(), c.RingSize())
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if owner := c.GetPartitionOwner(partID).String(); owner == "a" {
+			t.Fatalf("Expected partition %d to move away from a", partID)
+		}
+	}
+
+	// Nothing changes if the ring already has the desired members.
+	observer.redistributions = 0
+	res, err = c.Reconcile([]WeightedMember{
+		testWeightedMember{name: "c", weight: 3},
+		testWeightedMember{name: "b", weight: 2},
+	})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if res.Changed() || observer.redistributions != 0 {
+		t.Fatalf("Expected no changes, got %+v and %d redistributions", res, observer.redistributions)
+	}
+
+	res, err = c.Reconcile([]WeightedMember{
+		testWeightedMember{name: "b", weight: 1},
+		testWeightedMember{name: "c", weight: 3},
+	})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !reflect.DeepEqual(res.Updated, []string{"b"}) || len(res.Added) != 0 || len(res.Removed) != 0 {
+		t.Fatalf("Expected b to be updated, got %+v", res)
+	}
+	if c.GetTotalWeight() != 4 || c.RingSize() != 4*cfg.ReplicationFactor {
+		t.Fatalf("Expected total weight 4 and %d positions, got %d and %d",
+			4*cfg.ReplicationFactor, c.GetTotalWeight(), c.RingSize())
+	}
+
+	res, err = c.Reconcile(nil)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !reflect.DeepEqual(res.Removed, []string{"b", "c"}) || c.MembersCount() != 0 {
+		t.Fatalf("Expected all the members to be removed, got %+v", res)
+	}
+	if owner := c.GetPartitionOwner(0); owner != nil {
+		t.Fatalf("Expected no owner on an empty ring, got %s", owner)
+	}
+}
+
+func TestReconcile_Revert(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:       71,
+		ReplicationFactor:    20,
+		Load:                 1.25,
+		Hasher:               testWeightedHasher{},
+		MaxReplicasPerMember: 100,
+	}
+	c := NewWeighted([]WeightedMember{
+		testWeightedMember{name: "a", weight: 1},
+		testWeightedMember{name: "b", weight: 2},
+	}, cfg)
+	before := c.Clone()
+
+	_, err := c.Reconcile([]WeightedMember{
+		testWeightedMember{name: "b", weight: 2},
+		testWeightedMember{name: "c", weight: 10},
+	})
+	if err != ErrTooManyReplicas {
+		t.Fatalf("Expected ErrTooManyReplicas, got %v", err)
+	}
+	if !c.Equal(before) {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+
+	// Partition 0 doesn't fit any member once a is removed.
+	cfg.PartitionWeightFunc = func(partID int) float64 {
+		if partID == 0 {
+			return 200
+		}
+		return 1
+	}
+	c = NewWeighted([]WeightedMember{testWeightedMember{name: "a", weight: 4}}, cfg)
+	before = c.Clone()
+	_, err = c.Reconcile([]WeightedMember{
+		testWeightedMember{name: "b", weight: 1},
+		testWeightedMember{name: "c", weight: 1},
+	})
+	if !errors.Is(err, ErrNotEnoughRoom) {
+		t.Fatalf("Expected ErrNotEnoughRoom, got %v", err)
+	}
+	if !c.Equal(before) || !reflect.DeepEqual(c.sortedSet, before.sortedSet) {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+	if c.GetTotalWeight() != 4 {
+		t.Fatalf("Expected total weight 4, got %d", c.GetTotalWeight())
+	}
+}
+
+func TestSwap(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	sets := [2][]WeightedMember{}
+	inSet := [2]map[string]bool{{}, {}}
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 4; j++ {
+			name := fmt.Sprintf("set%d-node%d.olric", i, j)
+			sets[i] = append(sets[i], testWeightedMember{name: name, weight: j%2 + 1})
+			inSet[i][name] = true
+		}
+	}
+	c := NewWeighted(sets[0], cfg)
+
+	if err := c.Swap(sets[1]); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !c.Equal(NewWeighted(sets[1], cfg)) {
+		t.Fatal("Expected the ring of the new members")
+	}
+	if c.Adds() != 4 || c.Removes() != 4 {
+		t.Fatalf("Expected 4 adds and 4 removes, got %d and %d", c.Adds(), c.Removes())
+	}
+
+	// The readers see either one set or the other, never an empty ring or a mix of both.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				key := []byte(fmt.Sprintf("key-%d", n))
+				if owner := c.LocateKey(key); owner == nil {
+					errs <- fmt.Errorf("no owner of %s", key)
+					return
+				}
+				owners := c.PartitionOwners()
+				set := inSet[0]
+				if !set[owners[0]] {
+					set = inSet[1]
+				}
+				for partID, owner := range owners {
+					if !set[owner] {
+						errs <- fmt.Errorf("partition %d is owned by %q of the other set", partID, owner)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		if err := c.Swap(sets[i%2]); err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	before := c.Clone()
+	err := c.Swap([]WeightedMember{testWeightedMember{name: "", weight: 1}})
+	if err != ErrEmptyMemberName {
+		t.Fatalf("Expected ErrEmptyMemberName, got %v", err)
+	}
+	if !c.Equal(before) {
+		t.Fatal("Expected the ring to be unchanged")
+	}
+
+	// Swapping in an empty set empties the ring.
+	if err := c.Swap([]WeightedMember{}); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.MembersCount() != 0 || len(c.PartitionTable()) != 0 || len(c.LoadDistribution()) != 0 {
+		t.Fatal("Expected an empty ring")
+	}
+	if owner := c.LocateKey([]byte("key")); owner != nil {
+		t.Fatalf("Expected no owner, got %s", owner)
+	}
+}
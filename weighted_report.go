@@ -0,0 +1,201 @@
+package consistent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// MemberReport represents the load and weight information of a single member.
+type MemberReport struct {
+	Name         string  `json:"name"`
+	Weight       int     `json:"weight"`
+	Load         float64 `json:"load"`
+	ExpectedLoad float64 `json:"expected_load"`
+	Partitions   int     `json:"partitions"`
+}
+
+// DistributionReport is a consistent view of the load and weight distribution of a WeightedConsistent ring.
+type DistributionReport struct {
+	PartitionCount int
+	TotalWeight    int
+	AverageLoad    float64
+	Members        map[string]MemberReport
+}
+
+// MarshalJSON encodes the report with the members sorted by name, so that the output is stable.
+func (r DistributionReport) MarshalJSON() ([]byte, error) {
+	members := make([]MemberReport, 0, len(r.Members))
+	for _, member := range r.Members {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+
+	return json.Marshal(struct {
+		PartitionCount int            `json:"partition_count"`
+		TotalWeight    int            `json:"total_weight"`
+		AverageLoad    float64        `json:"average_load"`
+		Members        []MemberReport `json:"members"`
+	}{
+		PartitionCount: r.PartitionCount,
+		TotalWeight:    r.TotalWeight,
+		AverageLoad:    r.AverageLoad,
+		Members:        members,
+	})
+}
+
+// Report returns the load, weight, expected load and owned partition count of all members.
+// It's taken under a single read lock, so all the values belong to the same state of the ring.
+func (c *WeightedConsistent) Report() DistributionReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	avgLoad := c.averageLoad()
+	r := DistributionReport{
+		PartitionCount: int(c.partitionCount),
+		TotalWeight:    c.totalWeight,
+		AverageLoad:    avgLoad,
+		Members:        make(map[string]MemberReport, len(c.members)),
+	}
+	partitions := make(map[string]int)
+	for _, member := range c.partitions {
+		partitions[(*member).String()]++
+	}
+	for name, weight := range c.weights {
+		r.Members[name] = MemberReport{
+			Name:         name,
+			Weight:       weight,
+			Load:         c.loads[name],
+			ExpectedLoad: avgLoad * float64(weight),
+			Partitions:   partitions[name],
+		}
+	}
+	return r
+}
+
+// LoadImbalance returns the maximum ratio of a member's actual load to its expected load, which is the average
+// load multiplied by the member's weight. The bounded loads guarantee keeps it at or below 1. It returns 0 if the
+// ring is empty.
+func (c *WeightedConsistent) LoadImbalance() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	avgLoad := c.averageLoad()
+	if avgLoad == 0 {
+		return 0
+	}
+
+	var imbalance float64
+	for name, weight := range c.weights {
+		if ratio := c.loads[name] / (avgLoad * float64(weight)); ratio > imbalance {
+			imbalance = ratio
+		}
+	}
+	return imbalance
+}
+
+// LoadStdDev returns the population standard deviation of the load per weight unit of the members.
+// A perfectly balanced ring has a standard deviation of 0. It returns 0 if the ring is empty.
+func (c *WeightedConsistent) LoadStdDev() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.weights) == 0 {
+		return 0
+	}
+
+	// Sum in name order, the floating point sums depend on the order of the terms.
+	names := make([]string, 0, len(c.weights))
+	for name := range c.weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sum float64
+	for _, name := range names {
+		sum += c.loads[name] / float64(c.weights[name])
+	}
+	mean := sum / float64(len(c.weights))
+
+	var variance float64
+	for _, name := range names {
+		d := c.loads[name]/float64(c.weights[name]) - mean
+		variance += d * d
+	}
+	return math.Sqrt(variance / float64(len(c.weights)))
+}
+
+// ExpectedLoad returns the load the algorithm expects for the given member, which is the average load multiplied
+// by its weight. It returns 0 for unknown members.
+func (c *WeightedConsistent) ExpectedLoad(name string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.averageLoad() * float64(c.weights[name])
+}
+
+// OverloadedMembers returns the sorted names of the members whose actual load exceeds their expected load.
+// The bounded loads guarantee should keep it empty.
+func (c *WeightedConsistent) OverloadedMembers() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	avgLoad := c.averageLoad()
+	res := []string{}
+	for name, weight := range c.weights {
+		if c.loads[name] > avgLoad*float64(weight) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// LoadDelta compares the current loads with a previous result of LoadDistribution and returns the signed
+// difference of every member whose load changed. New members appear with their full load and removed
+// members with the negative of their previous load.
+func (c *WeightedConsistent) LoadDelta(previous map[string]float64) map[string]float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := make(map[string]float64)
+	for name := range c.members {
+		if delta := c.loads[name] - previous[name]; delta != 0 {
+			res[name] = delta
+		}
+	}
+	for name, load := range previous {
+		if _, ok := c.members[name]; !ok && load != 0 {
+			res[name] = -load
+		}
+	}
+	return res
+}
+
+// DumpState writes a deterministic textual representation of the ring to w: the partition count, the
+// members sorted by name with their weights and loads, and the owner of every partition, one per line.
+// It's meant for golden-file tests which catch unintended changes of the distribution. Unowned
+// partitions have the owner "-".
+func (c *WeightedConsistent) DumpState(w io.Writer) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "partitions %d\n", c.partitionCount)
+	fmt.Fprintf(bw, "total_weight %d\n", c.totalWeight)
+	for _, name := range c.sortedNames() {
+		fmt.Fprintf(bw, "member %s weight %d load %v\n", name, c.weights[name], c.loads[name])
+	}
+	for partID := 0; partID < int(c.partitionCount); partID++ {
+		owner := "-"
+		if member, ok := c.partitions[partID]; ok {
+			owner = (*member).String()
+		}
+		fmt.Fprintf(bw, "partition %d %s\n", partID, owner)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,304 @@
+package consistent
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWeightedConsistent_Report(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server2", weight: 1},
+		testWeightedMember{name: "server3", weight: 3},
+		testWeightedMember{name: "server1", weight: 2},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	r := c.Report()
+
+	if r.PartitionCount != 71 {
+		t.Fatalf("Expected partition count 71, got %d", r.PartitionCount)
+	}
+	if r.TotalWeight != 6 {
+		t.Fatalf("Expected total weight 6, got %d", r.TotalWeight)
+	}
+	if r.AverageLoad != c.AverageLoad() {
+		t.Fatalf("Expected average load %.0f, got %.0f", c.AverageLoad(), r.AverageLoad)
+	}
+	if len(r.Members) != 3 {
+		t.Fatalf("Expected 3 members in report, got %d", len(r.Members))
+	}
+
+	var partitions int
+	for _, member := range members {
+		m := r.Members[member.String()]
+		if m.Weight != member.Weight() {
+			t.Fatalf("Expected weight %d for %s, got %d", member.Weight(), member, m.Weight)
+		}
+		if m.Load != c.LoadDistribution()[member.String()] {
+			t.Fatalf("Expected load %.0f for %s, got %.0f", c.LoadDistribution()[member.String()], member, m.Load)
+		}
+		if m.ExpectedLoad != r.AverageLoad*float64(member.Weight()) {
+			t.Fatalf("Expected load of %s is wrong: %.0f", member, m.ExpectedLoad)
+		}
+		if m.Partitions != len(c.OwnedPartitions(member.String())) {
+			t.Fatalf("Expected %d partitions for %s, got %d", len(c.OwnedPartitions(member.String())), member, m.Partitions)
+		}
+		partitions += m.Partitions
+	}
+	if partitions != r.PartitionCount {
+		t.Fatalf("Expected %d owned partitions in total, got %d", r.PartitionCount, partitions)
+	}
+}
+
+func TestWeightedConsistent_ReportMarshalJSON(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server2", weight: 1},
+		testWeightedMember{name: "server3", weight: 3},
+		testWeightedMember{name: "server1", weight: 2},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+
+	data, err := json.Marshal(c.Report())
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		again, err := json.Marshal(c.Report())
+		if err != nil {
+			t.Fatalf("Marshal returned error: %v", err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Fatalf("JSON output is not stable:\n%s\n%s", data, again)
+		}
+	}
+
+	var decoded struct {
+		PartitionCount int            `json:"partition_count"`
+		Members        []MemberReport `json:"members"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if decoded.PartitionCount != 71 {
+		t.Fatalf("Expected partition count 71, got %d", decoded.PartitionCount)
+	}
+	if len(decoded.Members) != 3 {
+		t.Fatalf("Expected 3 members, got %d", len(decoded.Members))
+	}
+	for i, name := range []string{"server1", "server2", "server3"} {
+		if decoded.Members[i].Name != name {
+			t.Fatalf("Expected %s at index %d, got %s", name, i, decoded.Members[i].Name)
+		}
+	}
+}
+
+func TestWeightedConsistent_LoadImbalance(t *testing.T) {
+	members := make([]WeightedMember, 0, 10)
+	for i := 0; i < 10; i++ {
+		members = append(members, testWeightedMember{name: fmt.Sprintf("node%d.olric", i), weight: i%3 + 1})
+	}
+
+	for _, partitionCount := range []int{71, 271, 1021, 4099, 16381} {
+		c := NewWeighted(members, WeightedConfig{
+			PartitionCount:    partitionCount,
+			ReplicationFactor: 20,
+			Load:              1.25,
+			Hasher:            testWeightedHasher{},
+		})
+
+		imbalance := c.LoadImbalance()
+		if imbalance > 1 {
+			t.Fatalf("Expected the imbalance to be bounded by 1 with %d partitions, got %f", partitionCount, imbalance)
+		}
+		if partitionCount >= 1021 && math.Abs(imbalance-1) > 0.01 {
+			t.Fatalf("Expected the imbalance to approach 1 with %d partitions, got %f", partitionCount, imbalance)
+		}
+
+		// The standard deviation of the load per weight never exceeds the largest load per weight.
+		var maxLoad float64
+		loads := c.LoadDistribution()
+		for name, weight := range c.WeightDistribution() {
+			maxLoad = math.Max(maxLoad, loads[name]/float64(weight))
+		}
+		if stddev := c.LoadStdDev(); stddev <= 0 || stddev > maxLoad {
+			t.Fatalf("Expected the standard deviation in (0, %f] with %d partitions, got %f", maxLoad, partitionCount, stddev)
+		}
+	}
+
+	c := NewWeighted(nil, WeightedConfig{Hasher: testWeightedHasher{}})
+	if c.LoadImbalance() != 0 || c.LoadStdDev() != 0 {
+		t.Fatalf("Expected 0 on an empty ring, got %f and %f", c.LoadImbalance(), c.LoadStdDev())
+	}
+}
+
+func TestWeightedConsistent_LoadStdDevBalanced(t *testing.T) {
+	c := NewWeighted(nil, WeightedConfig{PartitionCount: 4, Hasher: testWeightedHasher{}})
+	c.weights = map[string]int{"node1.olric": 1, "node2.olric": 3}
+	c.loads = map[string]float64{"node1.olric": 1, "node2.olric": 3}
+	if stddev := c.LoadStdDev(); stddev != 0 {
+		t.Fatalf("Expected 0, got %f", stddev)
+	}
+
+	c.loads = map[string]float64{"node1.olric": 2, "node2.olric": 2}
+	// Loads per weight are 2 and 2/3, so the mean is 4/3 and the deviation is 2/3.
+	if stddev := c.LoadStdDev(); math.Abs(stddev-2.0/3) > 1e-9 {
+		t.Fatalf("Expected %f, got %f", 2.0/3, stddev)
+	}
+}
+
+func TestWeightedConsistent_ExpectedLoad(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 3},
+	}
+
+	c := NewWeighted(members, WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	})
+
+	avgLoad := c.AverageLoad()
+	if got := c.ExpectedLoad("node1.olric"); got != avgLoad {
+		t.Fatalf("Expected %f, got %f", avgLoad, got)
+	}
+	if got := c.ExpectedLoad("node2.olric"); got != avgLoad*3 {
+		t.Fatalf("Expected %f, got %f", avgLoad*3, got)
+	}
+	if got := c.ExpectedLoad("unknown"); got != 0 {
+		t.Fatalf("Expected 0 for an unknown member, got %f", got)
+	}
+
+	if overloaded := c.OverloadedMembers(); overloaded == nil || len(overloaded) != 0 {
+		t.Fatalf("Expected no overloaded members, got %v", overloaded)
+	}
+
+	c.loads["node1.olric"] = avgLoad + 1
+	overloaded := c.OverloadedMembers()
+	if len(overloaded) != 1 || overloaded[0] != "node1.olric" {
+		t.Fatalf("Expected node1.olric to be overloaded, got %v", overloaded)
+	}
+}
+
+func TestWeightedConsistent_LoadDelta(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 2},
+		testWeightedMember{name: "node3.olric", weight: 1},
+	}
+
+	c := NewWeighted(members, WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	})
+
+	previous := c.LoadDistribution()
+	if delta := c.LoadDelta(previous); len(delta) != 0 {
+		t.Fatalf("Expected no changes, got %v", delta)
+	}
+
+	c.Remove("node1.olric")
+	c.Add(testWeightedMember{name: "node4.olric", weight: 2})
+	current := c.LoadDistribution()
+	delta := c.LoadDelta(previous)
+
+	if delta["node1.olric"] != -previous["node1.olric"] {
+		t.Fatalf("Expected %f for the removed member, got %f", -previous["node1.olric"], delta["node1.olric"])
+	}
+	if delta["node4.olric"] != current["node4.olric"] {
+		t.Fatalf("Expected %f for the new member, got %f", current["node4.olric"], delta["node4.olric"])
+	}
+	for _, name := range []string{"node2.olric", "node3.olric"} {
+		d, ok := delta[name]
+		if current[name] == previous[name] && ok {
+			t.Fatalf("Expected %s to be omitted, got %f", name, d)
+		}
+		if current[name] != previous[name] && d != current[name]-previous[name] {
+			t.Fatalf("Expected %f for %s, got %f", current[name]-previous[name], name, d)
+		}
+	}
+
+	// Applying the delta to the previous loads gives the current ones.
+	for name, d := range delta {
+		previous[name] += d
+		if previous[name] == 0 {
+			delete(previous, name)
+		}
+	}
+	if len(previous) != len(current) {
+		t.Fatalf("Expected %d members, got %d", len(current), len(previous))
+	}
+	for name, load := range current {
+		if previous[name] != load {
+			t.Fatalf("Expected %f for %s, got %f", load, name, previous[name])
+		}
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestWeightedConsistent_DumpState(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server2", weight: 1},
+		testWeightedMember{name: "server3", weight: 3},
+		testWeightedMember{name: "server1", weight: 2},
+	}
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            DefaultHasher{},
+	}
+	c := NewWeighted(members, cfg)
+
+	var buf bytes.Buffer
+	if err := c.DumpState(&buf); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	golden := filepath.Join("testdata", "weighted_state.golden")
+	if *updateGolden {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("The state differs from %s, run the test with -update if the change is intended:\n%s", golden, buf.String())
+	}
+
+	// The same members in any order dump the same state.
+	var other bytes.Buffer
+	if err := NewWeighted([]WeightedMember{members[2], members[0], members[1]}, cfg).DumpState(&other); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), other.Bytes()) {
+		t.Fatal("Expected the same state regardless of the order of the members")
+	}
+}
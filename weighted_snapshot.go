@@ -0,0 +1,223 @@
+package consistent
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"sort"
+)
+
+// ErrSnapshotMismatch represents an error which means the restored ring doesn't match the snapshot.
+// This usually means that the provided Hasher is different from the one which created the snapshot.
+var ErrSnapshotMismatch = errors.New("snapshot doesn't match the restored ring")
+
+var snapshotTable = crc64.MakeTable(crc64.ECMA)
+
+type snapshotMember struct {
+	Name    string `json:"name"`
+	Weight  int    `json:"weight"`
+	HashKey []byte `json:"hash_key,omitempty"`
+	// DrainingWeight is the weight to restore of a draining member, see SetDraining. Weight is its
+	// draining weight then.
+	DrainingWeight int `json:"draining_weight,omitempty"`
+}
+
+type weightedSnapshot struct {
+	PartitionCount       int              `json:"partition_count"`
+	ReplicationFactor    int              `json:"replication_factor"`
+	Load                 float64          `json:"load"`
+	Seed                 uint64           `json:"seed"`
+	DisableBoundedLoad   bool             `json:"disable_bounded_load,omitempty"`
+	MinimalDisruption    bool             `json:"minimal_disruption,omitempty"`
+	StableTieBreak       bool             `json:"stable_tie_break,omitempty"`
+	MinReplicasPerMember int              `json:"min_replicas_per_member,omitempty"`
+	MaxReplicasPerMember int              `json:"max_replicas_per_member,omitempty"`
+	ReplicaSetSize       int              `json:"replica_set_size,omitempty"`
+	Members              []snapshotMember `json:"members"`
+	Partitions           []string         `json:"partitions"`
+	Backups              [][]string       `json:"backups,omitempty"`
+	Checksum             uint64           `json:"checksum"`
+}
+
+// restoredMember is the WeightedMember implementation used for the members of a restored ring.
+type restoredMember struct {
+	name    string
+	weight  int
+	hashKey []byte
+}
+
+func (m *restoredMember) String() string {
+	return m.name
+}
+
+func (m *restoredMember) Weight() int {
+	return m.weight
+}
+
+// HashKey returns the hash key of the original member, or its name if it didn't implement HashKeyer.
+func (m *restoredMember) HashKey() []byte {
+	if m.hashKey == nil {
+		return []byte(m.name)
+	}
+	return m.hashKey
+}
+
+// checksum calculates a checksum of the ring positions and the partition hashes. Both of them are
+// calculated by the Hasher, so the checksum only matches if the same Hasher is in use.
+func (c *WeightedConsistent) checksum() uint64 {
+	buf := make([]byte, 8)
+	crc := crc64.Update(0, snapshotTable, nil)
+	for _, h := range c.sortedSet {
+		binary.LittleEndian.PutUint64(buf, h)
+		crc = crc64.Update(crc, snapshotTable, buf)
+	}
+	for _, h := range c.partitionHashes {
+		binary.LittleEndian.PutUint64(buf, h)
+		crc = crc64.Update(crc, snapshotTable, buf)
+	}
+	return crc
+}
+
+// Snapshot serializes the member names, weights, hash keys, draining states, config and the computed
+// partition table of the ring.
+// The Hasher cannot be serialized, it has to be provided to RestoreWeighted.
+func (c *WeightedConsistent) Snapshot() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := weightedSnapshot{
+		PartitionCount:       c.config.PartitionCount,
+		ReplicationFactor:    c.config.ReplicationFactor,
+		Load:                 c.config.Load,
+		Seed:                 c.config.Seed,
+		DisableBoundedLoad:   c.config.DisableBoundedLoad,
+		MinimalDisruption:    c.config.MinimalDisruption,
+		StableTieBreak:       c.config.StableTieBreak,
+		MinReplicasPerMember: c.config.MinReplicasPerMember,
+		MaxReplicasPerMember: c.config.MaxReplicasPerMember,
+		ReplicaSetSize:       c.config.ReplicaSetSize,
+		Members:              make([]snapshotMember, 0, len(c.members)),
+		Partitions:           make([]string, c.partitionCount),
+		Checksum:             c.checksum(),
+	}
+	for name, weight := range c.weights {
+		sm := snapshotMember{Name: name, Weight: weight, DrainingWeight: c.draining[name]}
+		if hk, ok := (*c.members[name]).(HashKeyer); ok {
+			sm.HashKey = hk.HashKey()
+		}
+		s.Members = append(s.Members, sm)
+	}
+	sort.Slice(s.Members, func(i, j int) bool {
+		return s.Members[i].Name < s.Members[j].Name
+	})
+	for partID, member := range c.partitions {
+		s.Partitions[partID] = (*member).String()
+	}
+	if c.backups != nil {
+		s.Backups = make([][]string, c.partitionCount)
+		for partID, set := range c.backups {
+			for _, member := range set {
+				s.Backups[partID] = append(s.Backups[partID], (*member).String())
+			}
+		}
+	}
+	return json.Marshal(s)
+}
+
+// RestoreWeighted creates a WeightedConsistent object from a snapshot taken by Snapshot. The members of
+// the restored ring only carry the names, weights and hash keys. It returns ErrSnapshotMismatch if the given
+// hasher doesn't produce the same ring as the snapshot recorded.
+//
+// The optional functions of the config, e.g. PartitionWeightFunc, cannot be serialized, so the restored ring
+// doesn't have them. The partition table is restored as it was, but the loads are recomputed with a weight of
+// 1 per partition and the later redistributions don't take the partition weights into account.
+func RestoreWeighted(data []byte, hasher Hasher) (*WeightedConsistent, error) {
+	var s weightedSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if hasher == nil {
+		return nil, errors.New("hasher cannot be nil")
+	}
+	if s.PartitionCount <= 0 || len(s.Partitions) != s.PartitionCount {
+		return nil, fmt.Errorf("invalid partition table in snapshot: %d partitions, %d owners",
+			s.PartitionCount, len(s.Partitions))
+	}
+	if s.Backups != nil && len(s.Backups) != s.PartitionCount {
+		return nil, fmt.Errorf("invalid backups in snapshot: %d partitions, %d backup sets",
+			s.PartitionCount, len(s.Backups))
+	}
+
+	c, err := NewWeightedChecked(nil, WeightedConfig{
+		Hasher:               hasher,
+		PartitionCount:       s.PartitionCount,
+		ReplicationFactor:    s.ReplicationFactor,
+		Load:                 s.Load,
+		Seed:                 s.Seed,
+		DisableBoundedLoad:   s.DisableBoundedLoad,
+		MinimalDisruption:    s.MinimalDisruption,
+		StableTieBreak:       s.StableTieBreak,
+		MinReplicasPerMember: s.MinReplicasPerMember,
+		MaxReplicasPerMember: s.MaxReplicasPerMember,
+		ReplicaSetSize:       s.ReplicaSetSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range s.Members {
+		if m.DrainingWeight == 0 {
+			c.add(&restoredMember{name: m.Name, weight: m.Weight, hashKey: m.HashKey})
+			continue
+		}
+		// The member keeps the weight to restore, like the original member of a draining ring.
+		c.addWithWeight(&restoredMember{name: m.Name, weight: m.DrainingWeight, hashKey: m.HashKey}, m.Weight)
+		if c.draining == nil {
+			c.draining = make(map[string]int)
+		}
+		c.draining[m.Name] = m.DrainingWeight
+	}
+	if c.checksum() != s.Checksum {
+		return nil, ErrSnapshotMismatch
+	}
+
+	partitions := make(map[int]*WeightedMember)
+	loads := make(map[string]float64)
+	for partID, name := range s.Partitions {
+		if name == "" {
+			// The partition was left unassigned, e.g. by OnDistributeFailure.
+			continue
+		}
+		member, ok := c.members[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown owner of partition %d in snapshot: %q", partID, name)
+		}
+		partitions[partID] = member
+		loads[name] += c.partitionWeight(partID)
+	}
+	var backups map[int][]*WeightedMember
+	if s.Backups != nil {
+		backups = make(map[int][]*WeightedMember, s.PartitionCount)
+		for partID, names := range s.Backups {
+			if len(names) == 0 {
+				continue
+			}
+			set := make([]*WeightedMember, 0, len(names))
+			for _, name := range names {
+				member, ok := c.members[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown backup of partition %d in snapshot: %q", partID, name)
+				}
+				set = append(set, member)
+				loads[name] += c.partitionWeight(partID)
+			}
+			backups[partID] = set
+		}
+	}
+	c.partitions = partitions
+	c.backups = backups
+	c.loads = loads
+	c.publish()
+	return c, nil
+}
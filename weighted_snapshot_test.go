@@ -0,0 +1,349 @@
+package consistent
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWeightedConsistent_SnapshotRestore(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+		testWeightedMember{name: "server3", weight: 3},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		Seed:              7,
+	}
+
+	c := NewWeighted(members, cfg)
+	c.Add(testWeightedMember{name: "server4", weight: 2})
+	c.Remove("server2")
+
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+
+	restored, err := RestoreWeighted(data, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("RestoreWeighted returned error: %v", err)
+	}
+
+	if restored.GetTotalWeight() != c.GetTotalWeight() {
+		t.Fatalf("Expected total weight %d, got %d", c.GetTotalWeight(), restored.GetTotalWeight())
+	}
+	for name, weight := range c.WeightDistribution() {
+		if restored.WeightDistribution()[name] != weight {
+			t.Fatalf("Expected weight %d for %s, got %d", weight, name, restored.WeightDistribution()[name])
+		}
+	}
+	for name, load := range c.LoadDistribution() {
+		if restored.LoadDistribution()[name] != load {
+			t.Fatalf("Expected load %.0f for %s, got %.0f", load, name, restored.LoadDistribution()[name])
+		}
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID).String() != restored.GetPartitionOwner(partID).String() {
+			t.Fatalf("Partition %d has a different owner after restore", partID)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if c.LocateKey(key).String() != restored.LocateKey(key).String() {
+			t.Fatalf("%s is located on a different member after restore", key)
+		}
+	}
+
+	// The restored ring is fully functional.
+	restored.Add(testWeightedMember{name: "server5", weight: 1})
+	if len(restored.GetMembers()) != 4 {
+		t.Fatalf("Expected 4 members, got %d", len(restored.GetMembers()))
+	}
+}
+
+func TestWeightedConsistent_RestoreWithDifferentHasher(t *testing.T) {
+	members := []WeightedMember{
+		testWeightedMember{name: "server1", weight: 2},
+		testWeightedMember{name: "server2", weight: 1},
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	c := NewWeighted(members, cfg)
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+
+	_, err = RestoreWeighted(data, testHasher{})
+	if err != ErrSnapshotMismatch {
+		t.Fatalf("Expected ErrSnapshotMismatch, got %v", err)
+	}
+
+	_, err = RestoreWeighted([]byte("garbage"), testWeightedHasher{})
+	if err == nil {
+		t.Fatal("Expected an error for invalid snapshot data")
+	}
+}
+
+func TestWeightedConsistent_SnapshotUnassignedPartition(t *testing.T) {
+	const heavyPartition = 0
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		PartitionWeightFunc: func(partID int) float64 {
+			if partID == heavyPartition {
+				return 200
+			}
+			return 1
+		},
+		OnDistributeFailure: func(int) {},
+	}
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 1},
+	}
+	c := NewWeighted(members, cfg)
+	if c.GetPartitionOwner(heavyPartition) != nil {
+		t.Fatalf("Expected partition %d to be unassigned", heavyPartition)
+	}
+
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+	restored, err := RestoreWeighted(data, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("RestoreWeighted returned error: %v", err)
+	}
+
+	if owner := restored.GetPartitionOwner(heavyPartition); owner != nil {
+		t.Fatalf("Expected no owner of partition %d, got %s", heavyPartition, owner)
+	}
+	for partID := 1; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID).String() != restored.GetPartitionOwner(partID).String() {
+			t.Fatalf("Partition %d has a different owner after restore", partID)
+		}
+	}
+}
+
+func TestWeightedConsistent_SnapshotHashKey(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	members := []WeightedMember{
+		hashKeyTestMember{testWeightedMember{name: "web1", weight: 2}, "0b5c7e3a"},
+		hashKeyTestMember{testWeightedMember{name: "web2", weight: 1}, "9f1d24c6"},
+		testWeightedMember{name: "web3", weight: 1},
+	}
+	c := NewWeighted(members, cfg)
+
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+	restored, err := RestoreWeighted(data, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("RestoreWeighted returned error: %v", err)
+	}
+
+	for _, m := range members {
+		name := m.String()
+		if !reflect.DeepEqual(c.MemberPositions(name), restored.MemberPositions(name)) {
+			t.Fatalf("%s has different positions after restore", name)
+		}
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID).String() != restored.GetPartitionOwner(partID).String() {
+			t.Fatalf("Partition %d has a different owner after restore", partID)
+		}
+	}
+}
+
+func TestWeightedConsistent_SnapshotReplicaSet(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:       71,
+		ReplicationFactor:    20,
+		Load:                 1.25,
+		Hasher:               testWeightedHasher{},
+		ReplicaSetSize:       3,
+		MaxReplicasPerMember: 60,
+	}
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 2},
+		testWeightedMember{name: "node3.olric", weight: 1},
+		testWeightedMember{name: "node4.olric", weight: 2},
+	}
+	c := NewWeighted(members, cfg)
+
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+	restored, err := RestoreWeighted(data, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("RestoreWeighted returned error: %v", err)
+	}
+
+	if restored.config.ReplicaSetSize != cfg.ReplicaSetSize {
+		t.Fatalf("Expected ReplicaSetSize %d, got %d", cfg.ReplicaSetSize, restored.config.ReplicaSetSize)
+	}
+	if restored.config.MaxReplicasPerMember != cfg.MaxReplicasPerMember {
+		t.Fatalf("Expected MaxReplicasPerMember %d, got %d", cfg.MaxReplicasPerMember, restored.config.MaxReplicasPerMember)
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		expected := c.GetReplicaSet(partID)
+		got := restored.GetReplicaSet(partID)
+		if len(got) != cfg.ReplicaSetSize {
+			t.Fatalf("Expected %d members in the replica set of partition %d, got %d", cfg.ReplicaSetSize, partID, len(got))
+		}
+		for i := range expected {
+			if expected[i].String() != got[i].String() {
+				t.Fatalf("Partition %d has a different replica set after restore", partID)
+			}
+		}
+	}
+	if !reflect.DeepEqual(c.LoadDistribution(), restored.LoadDistribution()) {
+		t.Fatalf("Expected loads %v, got %v", c.LoadDistribution(), restored.LoadDistribution())
+	}
+
+	// The restored ring keeps rejecting the members above the cap.
+	if err := restored.AddChecked(testWeightedMember{name: "node5.olric", weight: 4}); !errors.Is(err, ErrTooManyReplicas) {
+		t.Fatalf("Expected ErrTooManyReplicas, got %v", err)
+	}
+}
+
+func TestWeightedConsistent_SnapshotDraining(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 3},
+		testWeightedMember{name: "node2.olric", weight: 2},
+		testWeightedMember{name: "node3.olric", weight: 1},
+	}
+	c := NewWeighted(members, cfg)
+	if err := c.SetDraining("node1.olric", true); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+	restored, err := RestoreWeighted(data, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("RestoreWeighted returned error: %v", err)
+	}
+
+	if !restored.IsDraining("node1.olric") {
+		t.Fatal("Expected node1.olric to be draining after restore")
+	}
+	if weight, _ := restored.Weight("node1.olric"); weight != drainingWeight {
+		t.Fatalf("Expected the draining weight %d, got %d", drainingWeight, weight)
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID).String() != restored.GetPartitionOwner(partID).String() {
+			t.Fatalf("Partition %d has a different owner after restore", partID)
+		}
+	}
+
+	// The original weight is restored when the member stops draining.
+	if err := restored.SetDraining("node1.olric", false); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if weight, _ := restored.Weight("node1.olric"); weight != 3 {
+		t.Fatalf("Expected weight 3, got %d", weight)
+	}
+	if restored.GetTotalWeight() != 6 {
+		t.Fatalf("Expected total weight 6, got %d", restored.GetTotalWeight())
+	}
+}
+
+func TestWeightedConsistent_SnapshotDropsPartitionWeightFunc(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		PartitionWeightFunc: func(partID int) float64 {
+			return float64(partID%3 + 1)
+		},
+	}
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 2},
+		testWeightedMember{name: "node2.olric", weight: 1},
+		testWeightedMember{name: "node3.olric", weight: 1},
+	}
+	c := NewWeighted(members, cfg)
+
+	data, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+	restored, err := RestoreWeighted(data, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("RestoreWeighted returned error: %v", err)
+	}
+
+	if restored.Config().PartitionWeightFunc != nil {
+		t.Fatal("Expected the restored ring to have no PartitionWeightFunc")
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if c.GetPartitionOwner(partID).String() != restored.GetPartitionOwner(partID).String() {
+			t.Fatalf("Partition %d has a different owner after restore", partID)
+		}
+	}
+	// The loads count every partition once.
+	for name, load := range restored.LoadDistribution() {
+		if owned := float64(len(restored.OwnedPartitions(name))); load != owned {
+			t.Fatalf("Expected load %.0f for %s, got %.0f", owned, name, load)
+		}
+	}
+}
+
+func TestWeightedConsistent_SnapshotEmptyRing(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+	}
+
+	data, err := NewWeighted(nil, cfg).Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot returned error: %v", err)
+	}
+
+	restored, err := RestoreWeighted(data, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("RestoreWeighted returned error: %v", err)
+	}
+	if len(restored.GetMembers()) != 0 {
+		t.Fatalf("Expected 0 members, got %d", len(restored.GetMembers()))
+	}
+	if restored.LocateKey([]byte("test-key")) != nil {
+		t.Fatal("Expected nil owner on an empty ring")
+	}
+}
@@ -0,0 +1,94 @@
+package consistent
+
+import (
+	"context"
+	"sort"
+)
+
+// RingState is a serializable description of a WeightedConsistent ring: its config and its members with
+// their current weights. The Hasher, the optional functions of the config and the computed partition table
+// are not part of it. It can be transferred with encoding/gob, the concrete types of the members have to
+// be registered with gob.Register on both sides.
+type RingState struct {
+	PartitionCount       int
+	ReplicationFactor    int
+	Load                 float64
+	Seed                 uint64
+	DisableBoundedLoad   bool
+	MinimalDisruption    bool
+	StableTieBreak       bool
+	MinReplicasPerMember int
+	MaxReplicasPerMember int
+	ReplicaSetSize       int
+	Members              []WeightedMember
+	Weights              map[string]int
+}
+
+// State returns the RingState of the ring. The members are sorted by name.
+func (c *WeightedConsistent) State() RingState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := RingState{
+		PartitionCount:       int(c.partitionCount),
+		ReplicationFactor:    c.config.ReplicationFactor,
+		Load:                 c.config.Load,
+		Seed:                 c.config.Seed,
+		DisableBoundedLoad:   c.config.DisableBoundedLoad,
+		MinimalDisruption:    c.config.MinimalDisruption,
+		StableTieBreak:       c.config.StableTieBreak,
+		MinReplicasPerMember: c.config.MinReplicasPerMember,
+		MaxReplicasPerMember: c.config.MaxReplicasPerMember,
+		ReplicaSetSize:       c.config.ReplicaSetSize,
+		Members:              make([]WeightedMember, 0, len(c.members)),
+		Weights:              make(map[string]int, len(c.weights)),
+	}
+	for name, member := range c.members {
+		s.Members = append(s.Members, *member)
+		s.Weights[name] = c.weights[name]
+	}
+	sort.Slice(s.Members, func(i, j int) bool {
+		return s.Members[i].String() < s.Members[j].String()
+	})
+	return s
+}
+
+// NewWeightedFromState creates a WeightedConsistent object from a RingState with the given hasher. The members
+// are placed with the weights recorded in the state and the partition table is computed from scratch, so it
+// only depends on the state and the hasher. It may differ from the table of the ring the state was taken
+// from, which depends on the order of its mutations; use Snapshot to transfer the exact table.
+func NewWeightedFromState(state RingState, hasher Hasher) (*WeightedConsistent, error) {
+	c, err := NewWeightedChecked(nil, WeightedConfig{
+		Hasher:               hasher,
+		PartitionCount:       state.PartitionCount,
+		ReplicationFactor:    state.ReplicationFactor,
+		Load:                 state.Load,
+		Seed:                 state.Seed,
+		DisableBoundedLoad:   state.DisableBoundedLoad,
+		MinimalDisruption:    state.MinimalDisruption,
+		StableTieBreak:       state.StableTieBreak,
+		MinReplicasPerMember: state.MinReplicasPerMember,
+		MaxReplicasPerMember: state.MaxReplicasPerMember,
+		ReplicaSetSize:       state.ReplicaSetSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range state.Members {
+		if _, ok := c.members[member.String()]; ok {
+			continue
+		}
+		weight, ok := state.Weights[member.String()]
+		if !ok {
+			weight = member.Weight()
+		}
+		c.addWithWeight(member, weight)
+	}
+	if len(c.members) == 0 {
+		return c, nil
+	}
+	if err := c.distributePartitions(context.Background()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
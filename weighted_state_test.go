@@ -0,0 +1,141 @@
+package consistent
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// gobTestMember has exported fields, so that encoding/gob can transfer it.
+type gobTestMember struct {
+	Name     string
+	Capacity int
+	Region   string
+}
+
+func (m gobTestMember) String() string {
+	return m.Name
+}
+
+func (m gobTestMember) Weight() int {
+	return m.Capacity
+}
+
+func TestWeightedConsistent_StateGob(t *testing.T) {
+	gob.Register(gobTestMember{})
+
+	members := make([]WeightedMember, 0, 6)
+	for i := 0; i < 6; i++ {
+		members = append(members, gobTestMember{
+			Name:     fmt.Sprintf("node%d.olric", i),
+			Capacity: i%3 + 1,
+			Region:   fmt.Sprintf("region-%d", i%2),
+		})
+	}
+
+	cfg := WeightedConfig{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testWeightedHasher{},
+		Seed:              42,
+	}
+
+	c := NewWeighted(members, cfg)
+	if err := c.UpdateWeight("node0.olric", 4); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(c.State()); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	var state RingState
+	if err := gob.NewDecoder(&buf).Decode(&state); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+
+	restored, err := NewWeightedFromState(state, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if restored.PartitionCount() != cfg.PartitionCount || restored.config.Seed != cfg.Seed {
+		t.Fatal("Expected the config to be restored")
+	}
+	if !reflect.DeepEqual(restored.WeightDistribution(), c.WeightDistribution()) {
+		t.Fatalf("Expected weights %v, got %v", c.WeightDistribution(), restored.WeightDistribution())
+	}
+	if !reflect.DeepEqual(restored.sortedSet, c.sortedSet) {
+		t.Fatal("Expected the same ring positions")
+	}
+	for _, member := range restored.GetMembers() {
+		m, ok := member.(gobTestMember)
+		if !ok {
+			t.Fatalf("Expected gobTestMember, got %T", member)
+		}
+		if m.Region == "" {
+			t.Fatalf("Expected the region of %s to be transferred", m)
+		}
+	}
+
+	// The partition table only depends on the state.
+	again, err := NewWeightedFromState(c.State(), testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if !reflect.DeepEqual(restored.PartitionTable(), again.PartitionTable()) {
+		t.Fatal("Expected the same partition table")
+	}
+}
+
+func TestWeightedConsistent_StateReplicaSet(t *testing.T) {
+	cfg := WeightedConfig{
+		PartitionCount:       71,
+		ReplicationFactor:    20,
+		Load:                 1.25,
+		Hasher:               testWeightedHasher{},
+		ReplicaSetSize:       3,
+		MaxReplicasPerMember: 60,
+	}
+	members := []WeightedMember{
+		testWeightedMember{name: "node1.olric", weight: 1},
+		testWeightedMember{name: "node2.olric", weight: 2},
+		testWeightedMember{name: "node3.olric", weight: 1},
+	}
+	c := NewWeighted(members, cfg)
+
+	state := c.State()
+	if state.ReplicaSetSize != cfg.ReplicaSetSize || state.MaxReplicasPerMember != cfg.MaxReplicasPerMember {
+		t.Fatalf("Expected ReplicaSetSize %d and MaxReplicasPerMember %d, got %d and %d",
+			cfg.ReplicaSetSize, cfg.MaxReplicasPerMember, state.ReplicaSetSize, state.MaxReplicasPerMember)
+	}
+	restored, err := NewWeightedFromState(state, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if len(restored.GetReplicaSet(partID)) != cfg.ReplicaSetSize {
+			t.Fatalf("Expected %d members in the replica set of partition %d, got %d",
+				cfg.ReplicaSetSize, partID, len(restored.GetReplicaSet(partID)))
+		}
+	}
+	if err := restored.AddChecked(testWeightedMember{name: "node4.olric", weight: 4}); !errors.Is(err, ErrTooManyReplicas) {
+		t.Fatalf("Expected ErrTooManyReplicas, got %v", err)
+	}
+}
+
+func TestNewWeightedFromState_Empty(t *testing.T) {
+	c, err := NewWeightedFromState(RingState{}, testWeightedHasher{})
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if c.MembersCount() != 0 || c.LocateKey([]byte("key")) != nil {
+		t.Fatal("Expected an empty ring")
+	}
+	if c.PartitionCount() != DefaultPartitionCount {
+		t.Fatalf("Expected %d partitions, got %d", DefaultPartitionCount, c.PartitionCount())
+	}
+}
@@ -3,12 +3,26 @@ package consistent
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 )
 
 // WeightedWrapper wraps the base Consistent struct to provide weighted functionality.
+//
+// The embedded Consistent only holds the virtual nodes of the weighted members. Its methods which add
+// or remove members must not be called directly: Add and Remove are shadowed by the weighted versions,
+// and the lookups ignore the members which were added to w.Consistent bypassing the wrapper.
 type WeightedWrapper struct {
 	*Consistent
+
+	// mu guards weights and members. Writers hold it during the whole mutation of the underlying
+	// ring, so readers never see a member whose virtual nodes are partially added.
+	mu      sync.RWMutex
 	weights map[string]int
+
+	// members holds the original weighted members as passed by the caller, so that they
+	// are returned as they are, metadata included.
+	members map[string]WeightedMember
 }
 
 // NewWeightedWrapper creates a new weighted consistent hash ring by wrapping the base implementation.
@@ -16,6 +30,7 @@ func NewWeightedWrapper(members []WeightedMember, config Config) *WeightedWrappe
 	// Convert weighted members to regular members with weight-based replication
 	var expandedMembers []Member
 	weights := make(map[string]int)
+	originals := make(map[string]WeightedMember)
 
 	for _, wmember := range members {
 		weight := wmember.Weight()
@@ -23,6 +38,7 @@ func NewWeightedWrapper(members []WeightedMember, config Config) *WeightedWrappe
 			weight = 1 // Ensure minimum weight of 1
 		}
 		weights[wmember.String()] = weight
+		originals[wmember.String()] = wmember
 
 		// Create multiple copies of the member based on its weight
 		for i := 0; i < weight; i++ {
@@ -39,6 +55,7 @@ func NewWeightedWrapper(members []WeightedMember, config Config) *WeightedWrappe
 	return &WeightedWrapper{
 		Consistent: baseConsistent,
 		weights:    weights,
+		members:    originals,
 	}
 }
 
@@ -52,8 +69,46 @@ func (w *weightedMemberWrapper) String() string {
 	return fmt.Sprintf("%s#%d", w.member.String(), w.suffix)
 }
 
+// unitWeightMember gives a plain Member the weight 1, see WeightedWrapper.Add.
+type unitWeightMember struct {
+	Member
+}
+
+func (unitWeightMember) Weight() int {
+	return 1
+}
+
+// unwrapMember returns the weighted member of the given virtual node. ok is false if the member isn't a virtual
+// node, which means it was added to the embedded Consistent directly.
+func unwrapMember(member Member) (WeightedMember, bool) {
+	wrapper, ok := member.(*weightedMemberWrapper)
+	if !ok {
+		return nil, false
+	}
+	return wrapper.member, true
+}
+
+// Add adds the member like AddWeighted if it implements WeightedMember and with weight 1 otherwise. It shadows
+// Consistent.Add, which would add the member without its virtual nodes.
+func (w *WeightedWrapper) Add(member Member) {
+	if wmember, ok := member.(WeightedMember); ok {
+		w.AddWeighted(wmember)
+		return
+	}
+	w.AddWeighted(unitWeightMember{member})
+}
+
+// Remove removes a weighted member like RemoveWeighted. It shadows Consistent.Remove, which would only remove
+// a single virtual node.
+func (w *WeightedWrapper) Remove(name string) {
+	w.RemoveWeighted(name)
+}
+
 // AddWeighted adds a new weighted member to the consistent hash circle.
 func (w *WeightedWrapper) AddWeighted(member WeightedMember) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	weight := member.Weight()
 	if weight <= 0 {
 		weight = 1
@@ -65,6 +120,7 @@ func (w *WeightedWrapper) AddWeighted(member WeightedMember) {
 	}
 
 	w.weights[member.String()] = weight
+	w.members[member.String()] = member
 
 	// Add multiple copies based on weight
 	for i := 0; i < weight; i++ {
@@ -76,58 +132,122 @@ func (w *WeightedWrapper) AddWeighted(member WeightedMember) {
 	}
 }
 
-// RemoveWeighted removes a weighted member from the consistent hash circle.
+// RemoveWeighted removes a weighted member from the consistent hash circle. All of its virtual nodes are
+// removed under the lock of the underlying ring and the partitions are redistributed once, so the ring is
+// never observed with some of the virtual nodes removed.
 func (w *WeightedWrapper) RemoveWeighted(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	weight, exists := w.weights[name]
 	if !exists {
 		return
 	}
 
+	w.Consistent.mu.Lock()
+	defer w.Consistent.mu.Unlock()
+
 	// Remove all virtual nodes for this member
 	for i := 0; i < weight; i++ {
 		virtualName := fmt.Sprintf("%s#%d", name, i)
-		w.Consistent.Remove(virtualName)
+		w.Consistent.remove(virtualName)
 	}
+	w.Consistent.redistribute()
 
 	delete(w.weights, name)
+	delete(w.members, name)
+}
+
+// UpdateWeight changes the weight of a member without removing it. Only the difference is applied: the
+// virtual nodes with the suffixes from the current weight up to the new one are added, or the ones with the
+// highest suffixes are removed, and the partitions are redistributed once. It returns ErrMemberNotFound if
+// the member is not in the ring.
+func (w *WeightedWrapper) UpdateWeight(name string, newWeight int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	weight, exists := w.weights[name]
+	if !exists {
+		return ErrMemberNotFound
+	}
+	if newWeight <= 0 {
+		newWeight = 1
+	}
+	if newWeight == weight {
+		return nil
+	}
+
+	w.Consistent.mu.Lock()
+	defer w.Consistent.mu.Unlock()
+
+	member := w.members[name]
+	for i := weight; i < newWeight; i++ {
+		w.Consistent.add(&weightedMemberWrapper{
+			member: member,
+			suffix: i,
+		})
+	}
+	for i := newWeight; i < weight; i++ {
+		w.Consistent.remove(fmt.Sprintf("%s#%d", name, i))
+	}
+	w.Consistent.redistribute()
+
+	w.weights[name] = newWeight
+	return nil
 }
 
-// LocateKeyWeighted finds a home for given key and returns the original weighted member
+// LocateKeyWeighted finds a home for given key and returns the original weighted member, the same value
+// which was passed to NewWeightedWrapper or AddWeighted. It returns nil if there are no members or the owner
+// of the key was added to the embedded Consistent directly.
 func (w *WeightedWrapper) LocateKeyWeighted(key []byte) WeightedMember {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
 	virtualMember := w.Consistent.LocateKey(key)
 	if virtualMember == nil {
 		return nil
 	}
 
 	// Extract the original member from the virtual wrapper
-	if wrapper, ok := virtualMember.(*weightedMemberWrapper); ok {
-		return wrapper.member
+	member, ok := unwrapMember(virtualMember)
+	if !ok {
+		return nil
 	}
+	return member
+}
 
-	return nil
+// LocateKeyString is like LocateKeyWeighted but takes a string key. The key is copied to a byte slice
+// internally.
+func (w *WeightedWrapper) LocateKeyString(key string) WeightedMember {
+	return w.LocateKeyWeighted([]byte(key))
 }
 
-// GetWeightedMembers returns a list of original weighted members (without duplicates)
+// FindPartitionIDString is like FindPartitionID but takes a string key.
+func (w *WeightedWrapper) FindPartitionIDString(key string) int {
+	return w.FindPartitionID([]byte(key))
+}
+
+// GetWeightedMembers returns a list of original weighted members (without duplicates) sorted by name, the same
+// values which were passed to NewWeightedWrapper or AddWeighted.
 func (w *WeightedWrapper) GetWeightedMembers() []WeightedMember {
-	var result []WeightedMember
-	seen := make(map[string]bool)
-
-	allMembers := w.Consistent.GetMembers()
-	for _, member := range allMembers {
-		if wrapper, ok := member.(*weightedMemberWrapper); ok {
-			memberName := wrapper.member.String()
-			if !seen[memberName] {
-				seen[memberName] = true
-				result = append(result, wrapper.member)
-			}
-		}
-	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
 
+	result := make([]WeightedMember, 0, len(w.members))
+	for _, member := range w.members {
+		result = append(result, member)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
 	return result
 }
 
 // GetWeights returns a copy of the weight distribution
 func (w *WeightedWrapper) GetWeights() map[string]int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
 	result := make(map[string]int)
 	for name, weight := range w.weights {
 		result[name] = weight
@@ -135,10 +255,40 @@ func (w *WeightedWrapper) GetWeights() map[string]int {
 	return result
 }
 
-// GetClosestNWeighted returns the closest N weighted members to a key
+// VirtualNodeCount returns the number of ring entries of the given weighted member, which is its weight
+// (the number of its copies) multiplied by ReplicationFactor. It returns 0 for unknown members.
+func (w *WeightedWrapper) VirtualNodeCount(name string) int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.weights[name] * w.Consistent.config.ReplicationFactor
+}
+
+// TotalVirtualNodes returns the number of ring entries of all weighted members.
+func (w *WeightedWrapper) TotalVirtualNodes() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var total int
+	for _, weight := range w.weights {
+		total += weight
+	}
+	return total * w.Consistent.config.ReplicationFactor
+}
+
+// GetClosestNWeighted returns the closest N weighted members to a key. It walks the virtual nodes in replica
+// order once and skips the ones whose original member is already picked. It returns ErrInvalidCount if count
+// is not positive, like Consistent.GetClosestN. Like LocateKeyWeighted, it skips the members which were added
+// to the embedded Consistent directly.
 func (w *WeightedWrapper) GetClosestNWeighted(key []byte, count int) ([]WeightedMember, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
 	if count <= 0 {
-		return []WeightedMember{}, nil
+		return nil, ErrInvalidCount
+	}
+	if len(w.weights) == 0 {
+		return nil, ErrEmptyRing
 	}
 
 	// Check if we have enough unique members
@@ -146,63 +296,28 @@ func (w *WeightedWrapper) GetClosestNWeighted(key []byte, count int) ([]Weighted
 		return nil, ErrInsufficientMemberCount
 	}
 
-	var result []WeightedMember
-	seen := make(map[string]bool)
+	partID := w.Consistent.FindPartitionID(key)
 
-	// Calculate a reasonable multiplier based on the maximum weight
-	maxWeight := 1
-	for _, weight := range w.weights {
-		if weight > maxWeight {
-			maxWeight = weight
-		}
-	}
+	w.Consistent.mu.RLock()
+	defer w.Consistent.mu.RUnlock()
 
-	// Start with a reasonable estimate: count * maxWeight
-	// This ensures we get enough virtual members to find all unique members
-	requestCount := count * maxWeight
-
-	for {
-		virtualMembers, err := w.Consistent.GetClosestN(key, requestCount)
-		if err != nil {
-			return nil, err
+	result := make([]WeightedMember, 0, count)
+	seen := make(map[string]struct{}, count)
+	w.Consistent.walkClosest(partID, func(virtualMember Member) bool {
+		member, ok := unwrapMember(virtualMember)
+		if !ok {
+			return true
 		}
-
-		result = result[:0]   // Reset result slice
-		for k := range seen { // Clear seen map
-			delete(seen, k)
+		memberName := member.String()
+		if _, ok := seen[memberName]; !ok {
+			seen[memberName] = struct{}{}
+			result = append(result, member)
 		}
+		return len(result) < count
+	})
 
-		for _, virtualMember := range virtualMembers {
-			if len(result) >= count {
-				break
-			}
-
-			if wrapper, ok := virtualMember.(*weightedMemberWrapper); ok {
-				memberName := wrapper.member.String()
-				if !seen[memberName] {
-					seen[memberName] = true
-					result = append(result, wrapper.member)
-				}
-			}
-		}
-
-		// If we got enough unique members, we're done
-		if len(result) >= count {
-			return result[:count], nil
-		}
-
-		// If we've requested all available virtual members but still don't have enough unique ones
-		if requestCount >= len(w.Consistent.GetMembers()) {
-			if len(result) == 0 {
-				return nil, ErrInsufficientMemberCount
-			}
-			return result, nil
-		}
-
-		// Double the request count and try again
-		requestCount *= 2
-		if requestCount > len(w.Consistent.GetMembers()) {
-			requestCount = len(w.Consistent.GetMembers())
-		}
+	if len(result) < count {
+		return nil, ErrInsufficientMemberCount
 	}
+	return result, nil
 }
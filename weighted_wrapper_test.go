@@ -3,6 +3,7 @@ package consistent
 import (
 	"fmt"
 	"hash/fnv"
+	"sync"
 	"testing"
 )
 
@@ -132,3 +133,431 @@ func TestWeightedWrapperDistribution(t *testing.T) {
 		t.Errorf("Expected ratio of at least 5:1, got %.2f:1", ratio)
 	}
 }
+
+func TestWeightedWrapperRemoveAll(t *testing.T) {
+	members := []WeightedMember{
+		&wrapperTestMember{name: "node1.olric", weight: 3},
+		&wrapperTestMember{name: "node2.olric", weight: 2},
+	}
+
+	config := Config{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+
+	wrapper := NewWeightedWrapper(members, config)
+	wrapper.RemoveWeighted("node1.olric")
+	wrapper.RemoveWeighted("node2.olric")
+
+	if len(wrapper.GetWeights()) != 0 || len(wrapper.GetWeightedMembers()) != 0 {
+		t.Fatal("Expected no weighted members")
+	}
+	if len(wrapper.Consistent.members) != 0 || len(wrapper.Consistent.ring) != 0 || len(wrapper.Consistent.sortedSet) != 0 {
+		t.Fatalf("Expected an empty ring, got %d members and %d virtual nodes",
+			len(wrapper.Consistent.members), len(wrapper.Consistent.sortedSet))
+	}
+	if len(wrapper.Consistent.partitions) != 0 || len(wrapper.LoadDistribution()) != 0 {
+		t.Fatal("Expected an empty partition table")
+	}
+	if wrapper.LocateKeyWeighted([]byte("key")) != nil {
+		t.Fatal("Expected nil on an empty ring")
+	}
+
+	wrapper.AddWeighted(&wrapperTestMember{name: "node3.olric", weight: 2})
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if member := wrapper.LocateKeyWeighted(key); member == nil || member.String() != "node3.olric" {
+			t.Fatalf("Expected node3.olric to own %s, got %v", key, member)
+		}
+	}
+	if len(wrapper.Consistent.sortedSet) != 2*config.ReplicationFactor {
+		t.Fatalf("Expected %d virtual nodes, got %d", 2*config.ReplicationFactor, len(wrapper.Consistent.sortedSet))
+	}
+}
+
+func TestWeightedWrapperReturnsOriginalMembers(t *testing.T) {
+	originals := map[string]*typedTestMember{
+		"node1.olric": {name: "node1.olric", weight: 3, region: "eu"},
+		"node2.olric": {name: "node2.olric", weight: 1, region: "us"},
+	}
+
+	config := Config{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+
+	wrapper := NewWeightedWrapper([]WeightedMember{originals["node1.olric"], originals["node2.olric"]}, config)
+	originals["node3.olric"] = &typedTestMember{name: "node3.olric", weight: 2, region: "ap"}
+	wrapper.AddWeighted(originals["node3.olric"])
+
+	members := wrapper.GetWeightedMembers()
+	if len(members) != len(originals) {
+		t.Fatalf("Expected %d members, got %d", len(originals), len(members))
+	}
+	for _, member := range members {
+		if member.(*typedTestMember) != originals[member.String()] {
+			t.Fatalf("Expected the original pointer of %s", member)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		member := wrapper.LocateKeyWeighted(key).(*typedTestMember)
+		if member != originals[member.String()] {
+			t.Fatalf("Expected the original pointer of %s", member)
+		}
+		if member.region != originals[member.String()].region {
+			t.Fatalf("Expected region %s, got %s", originals[member.String()].region, member.region)
+		}
+
+		closest, err := wrapper.GetClosestNWeighted(key, 2)
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		for _, m := range closest {
+			if m.(*typedTestMember) != originals[m.String()] {
+				t.Fatalf("Expected the original pointer of %s", m)
+			}
+		}
+	}
+
+	wrapper.RemoveWeighted("node1.olric")
+	if len(wrapper.GetWeightedMembers()) != 2 {
+		t.Fatalf("Expected 2 members, got %d", len(wrapper.GetWeightedMembers()))
+	}
+}
+
+func TestWeightedWrapperVirtualNodeCount(t *testing.T) {
+	members := []WeightedMember{
+		&wrapperTestMember{name: "node1.olric", weight: 3},
+		&wrapperTestMember{name: "node2.olric", weight: 1},
+	}
+
+	config := Config{
+		PartitionCount:    71,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+
+	wrapper := NewWeightedWrapper(members, config)
+	if wrapper.VirtualNodeCount("node1.olric") != 60 {
+		t.Fatalf("Expected 60 virtual nodes, got %d", wrapper.VirtualNodeCount("node1.olric"))
+	}
+	if wrapper.VirtualNodeCount("node2.olric") != 20 {
+		t.Fatalf("Expected 20 virtual nodes, got %d", wrapper.VirtualNodeCount("node2.olric"))
+	}
+	if wrapper.VirtualNodeCount("unknown") != 0 {
+		t.Fatalf("Expected 0 virtual nodes for an unknown member, got %d", wrapper.VirtualNodeCount("unknown"))
+	}
+	if wrapper.TotalVirtualNodes() != 80 || wrapper.TotalVirtualNodes() != len(wrapper.Consistent.sortedSet) {
+		t.Fatalf("Expected 80 virtual nodes, got %d", wrapper.TotalVirtualNodes())
+	}
+
+	wrapper.RemoveWeighted("node1.olric")
+	if wrapper.TotalVirtualNodes() != 20 {
+		t.Fatalf("Expected 20 virtual nodes, got %d", wrapper.TotalVirtualNodes())
+	}
+}
+
+func TestWeightedWrapperLocateKeyString(t *testing.T) {
+	members := []WeightedMember{
+		&wrapperTestMember{name: "node1.olric", weight: 1},
+		&wrapperTestMember{name: "node2.olric", weight: 3},
+	}
+	cfg := Config{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+	w := NewWeightedWrapper(members, cfg)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if got, want := w.FindPartitionIDString(key), w.FindPartitionID([]byte(key)); got != want {
+			t.Fatalf("Expected partition %d for %s, got %d", want, key, got)
+		}
+		if got, want := w.LocateKeyString(key), w.LocateKeyWeighted([]byte(key)); got != want {
+			t.Fatalf("Expected owner %s for %s, got %s", want, key, got)
+		}
+	}
+}
+
+func TestWeightedWrapperGetClosestNInvalidCount(t *testing.T) {
+	members := []WeightedMember{
+		&wrapperTestMember{name: "node1.olric", weight: 1},
+		&wrapperTestMember{name: "node2.olric", weight: 3},
+	}
+	cfg := Config{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+	w := NewWeightedWrapper(members, cfg)
+
+	// The wrapper and the embedded ring must agree.
+	key := []byte("test-key")
+	for _, count := range []int{0, -1} {
+		if _, err := w.GetClosestNWeighted(key, count); err != ErrInvalidCount {
+			t.Fatalf("Expected ErrInvalidCount for %d, got %v", count, err)
+		}
+		if _, err := w.GetClosestN(key, count); err != ErrInvalidCount {
+			t.Fatalf("Expected ErrInvalidCount for %d from the embedded ring, got %v", count, err)
+		}
+	}
+}
+
+func TestWeightedWrapperUpdateWeight(t *testing.T) {
+	members := []WeightedMember{
+		&wrapperTestMember{name: "node1.olric", weight: 2},
+		&wrapperTestMember{name: "node2.olric", weight: 2},
+	}
+	cfg := Config{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+	w := NewWeightedWrapper(members, cfg)
+	stable := map[string]*Member{
+		"node1.olric#0": w.Consistent.members["node1.olric#0"],
+		"node1.olric#1": w.Consistent.members["node1.olric#1"],
+	}
+
+	if err := w.UpdateWeight("node1.olric", 4); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if w.GetWeights()["node1.olric"] != 4 {
+		t.Fatalf("Expected weight 4, got %d", w.GetWeights()["node1.olric"])
+	}
+	if w.VirtualNodeCount("node1.olric") != 4*cfg.ReplicationFactor {
+		t.Fatalf("Expected %d virtual nodes, got %d", 4*cfg.ReplicationFactor, w.VirtualNodeCount("node1.olric"))
+	}
+	// The existing virtual nodes are kept as they are, only #2 and #3 are added.
+	for name, member := range stable {
+		if w.Consistent.members[name] != member {
+			t.Fatalf("Expected %s to be kept", name)
+		}
+	}
+	for _, name := range []string{"node1.olric#2", "node1.olric#3"} {
+		if _, ok := w.Consistent.members[name]; !ok {
+			t.Fatalf("Expected %s to be added", name)
+		}
+	}
+	if len(w.Consistent.sortedSet) != 6*cfg.ReplicationFactor {
+		t.Fatalf("Expected %d positions, got %d", 6*cfg.ReplicationFactor, len(w.Consistent.sortedSet))
+	}
+
+	if err := w.UpdateWeight("node1.olric", 1); err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if w.GetWeights()["node1.olric"] != 1 {
+		t.Fatalf("Expected weight 1, got %d", w.GetWeights()["node1.olric"])
+	}
+	if w.Consistent.members["node1.olric#0"] != stable["node1.olric#0"] {
+		t.Fatal("Expected node1.olric#0 to be kept")
+	}
+	for _, name := range []string{"node1.olric#1", "node1.olric#2", "node1.olric#3"} {
+		if _, ok := w.Consistent.members[name]; ok {
+			t.Fatalf("Expected %s to be removed", name)
+		}
+	}
+	for partID := 0; partID < cfg.PartitionCount; partID++ {
+		if w.GetPartitionOwner(partID) == nil {
+			t.Fatalf("Expected an owner for partition %d", partID)
+		}
+	}
+
+	if err := w.UpdateWeight("unknown", 2); err != ErrMemberNotFound {
+		t.Fatalf("Expected ErrMemberNotFound, got %v", err)
+	}
+}
+
+func TestWeightedWrapperAddShadowsEmbedded(t *testing.T) {
+	config := Config{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+	w := NewWeightedWrapper([]WeightedMember{&wrapperTestMember{name: "server1", weight: 2}}, config)
+
+	w.Add(&wrapperTestMember{name: "server2", weight: 3})
+	w.Add(testMember("plain"))
+	weights := w.GetWeights()
+	if weights["server2"] != 3 || weights["plain"] != 1 {
+		t.Fatalf("Expected weights 3 and 1, got %v", weights)
+	}
+	if w.VirtualNodeCount("server2") != 3*config.ReplicationFactor {
+		t.Fatalf("Expected %d virtual nodes, got %d", 3*config.ReplicationFactor, w.VirtualNodeCount("server2"))
+	}
+
+	w.Remove("server2")
+	if _, ok := w.GetWeights()["server2"]; ok {
+		t.Fatal("Expected server2 to be removed")
+	}
+	if len(w.Consistent.members) != 3 {
+		t.Fatalf("Expected 3 virtual nodes left, got %d", len(w.Consistent.members))
+	}
+}
+
+func TestWeightedWrapperIgnoresBypassedAdd(t *testing.T) {
+	config := Config{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+	w := NewWeightedWrapper([]WeightedMember{&wrapperTestMember{name: "server1", weight: 2}}, config)
+	w.Consistent.Add(testMember("rogue"))
+
+	var key []byte
+	for i := 0; key == nil; i++ {
+		k := []byte(fmt.Sprintf("key-%d", i))
+		if w.Consistent.LocateKey(k).String() == "rogue" {
+			key = k
+		}
+	}
+
+	// The read paths don't panic on the member which isn't a virtual node.
+	if member := w.LocateKeyWeighted(key); member != nil {
+		t.Fatalf("Expected nil, got %s", member)
+	}
+	closest, err := w.GetClosestNWeighted(key, 1)
+	if err != nil {
+		t.Fatalf("Expected nil, got: %v", err)
+	}
+	if len(closest) != 1 || closest[0].String() != "server1" {
+		t.Fatalf("Expected server1, got %v", closest)
+	}
+}
+
+func TestWeightedWrapperConcurrentAccess(t *testing.T) {
+	members := []WeightedMember{
+		&wrapperTestMember{name: "server1", weight: 3},
+		&wrapperTestMember{name: "server2", weight: 1},
+	}
+
+	config := Config{
+		PartitionCount:    71,
+		ReplicationFactor: 10,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+
+	wrapper := NewWeightedWrapper(members, config)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				name := fmt.Sprintf("node%d-%d", i, j)
+				wrapper.AddWeighted(&wrapperTestMember{name: name, weight: j%3 + 1})
+				wrapper.RemoveWeighted(name)
+			}
+		}(i)
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := []byte(fmt.Sprintf("key-%d", j))
+				if wrapper.LocateKeyWeighted(key) == nil {
+					t.Errorf("Expected to find a member for %s", key)
+					return
+				}
+				if _, err := wrapper.GetClosestNWeighted(key, 2); err != nil {
+					t.Errorf("GetClosestNWeighted returned error: %v", err)
+					return
+				}
+				if len(wrapper.GetWeightedMembers()) < 2 || len(wrapper.GetWeights()) < 2 {
+					t.Errorf("Expected at least 2 members")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	weights := wrapper.GetWeights()
+	if len(weights) != 2 {
+		t.Fatalf("Expected 2 members after concurrent access, got %d", len(weights))
+	}
+	if len(wrapper.GetWeightedMembers()) != 2 {
+		t.Fatalf("Expected 2 weighted members, got %d", len(wrapper.GetWeightedMembers()))
+	}
+}
+
+func TestWeightedWrapperGetClosestNSkewedWeights(t *testing.T) {
+	members := []WeightedMember{&wrapperTestMember{name: "heavy.olric", weight: 100}}
+	for i := 0; i < 4; i++ {
+		members = append(members, &wrapperTestMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+
+	config := Config{
+		PartitionCount:    271,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+
+	wrapper := NewWeightedWrapper(members, config)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		closest, err := wrapper.GetClosestNWeighted(key, len(members))
+		if err != nil {
+			t.Fatalf("Expected nil, got: %v", err)
+		}
+		if len(closest) != len(members) {
+			t.Fatalf("Expected %d members, got %d", len(members), len(closest))
+		}
+		if closest[0] != wrapper.LocateKeyWeighted(key) {
+			t.Fatalf("Expected the owner %s first, got %s", wrapper.LocateKeyWeighted(key), closest[0])
+		}
+		seen := make(map[string]struct{})
+		for _, member := range closest {
+			if _, ok := seen[member.String()]; ok {
+				t.Fatalf("Duplicate member %s", member)
+			}
+			seen[member.String()] = struct{}{}
+		}
+	}
+}
+
+func BenchmarkWeightedWrapperGetClosestNSkewedWeights(b *testing.B) {
+	members := []WeightedMember{&wrapperTestMember{name: "heavy.olric", weight: 1000}}
+	for i := 0; i < 5; i++ {
+		members = append(members, &wrapperTestMember{name: fmt.Sprintf("node%d.olric", i), weight: 1})
+	}
+
+	config := Config{
+		PartitionCount:    2053,
+		ReplicationFactor: 20,
+		Load:              1.25,
+		Hasher:            testHasher{},
+	}
+
+	wrapper := NewWeightedWrapper(members, config)
+
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key-%d", i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := wrapper.GetClosestNWeighted(keys[i%len(keys)], 3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}